@@ -0,0 +1,329 @@
+package airtime
+
+// 汇聚节点下行调度：每个汇聚节点的无线发送能力有限，
+// 下行按优先级排队，受发送速率（令牌桶）和占空比（滑动窗口内的空口时长）约束，
+// 批量任务（优先级小于 0）之间再间隔 BulkInterval，避免一次性占满空口
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrFull    = errors.New("下行调度队列已满")
+	ErrDelay   = errors.New("空口预算不足，等待超时")
+	ErrStopped = errors.New("下行调度已停止")
+)
+
+// 调度参数
+type Config struct {
+	// 每秒最多下发帧数及突发上限
+	Rate  float64
+	Burst int
+	// 占空比 (0,1] 及统计窗口
+	DutyCycle  float64
+	DutyWindow time.Duration
+	// 估算单帧空口时长：固定开销 + 帧长 / 空口速率
+	BitRate       int
+	FrameOverhead time.Duration
+	// 批量任务之间的最小间隔
+	BulkInterval time.Duration
+	// 非批量下行最长排队时间，超过返回 ErrDelay
+	MaxDelay time.Duration
+	// 排队上限
+	QueueSize int
+}
+
+// 一条待调度的下行
+type Job struct {
+	ID       string
+	Eid      string
+	Frame    []byte
+	Priority int // 越大越先发，小于 0 为批量任务
+	// 轮到该下行时在单独的协程中调用，实际经传输发送；
+	// 同一突发内出队的多条下行交给传输的先后不作保证
+	Send func() error
+
+	seq      uint64
+	deadline time.Time
+	airtime  time.Duration
+	done     chan error
+}
+
+func (j *Job) bulk() bool { return j.Priority < 0 }
+
+// 单个汇聚节点的调度器
+type Scheduler struct {
+	sink string
+
+	mu       sync.Mutex
+	cfg      Config
+	q        jobHeap
+	seq      uint64
+	tokens   float64
+	refilled time.Time
+	usage    []usage // 窗口内各帧的发送时刻与空口时长
+	lastBulk time.Time
+	closed   bool
+
+	wake chan struct{}
+	stop chan struct{}
+}
+
+type usage struct {
+	at time.Time
+	d  time.Duration
+}
+
+// 创建 sink 的调度器并启动发送协程
+func New(sink string, c Config) *Scheduler {
+	s := &Scheduler{
+		sink:     sink,
+		cfg:      c,
+		tokens:   float64(c.Burst),
+		refilled: time.Now(),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// 更新调度参数，运行时修改即生效
+func (s *Scheduler) Configure(c Config) {
+	s.mu.Lock()
+	s.cfg = c
+	if s.tokens > float64(c.Burst) {
+		s.tokens = float64(c.Burst)
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// 排队等待发送，返回 Send 的结果；批量任务不受 MaxDelay 限制
+func (s *Scheduler) Do(j Job) error {
+	done, err := s.enqueue(j)
+	if err != nil {
+		return err
+	}
+	return <-done
+}
+
+// 排队后即返回，不等待发送（用于无人等待结果的协议应答）；
+// 只返回排队失败，发送失败由 Send 自行处理，排队超时只记录日志
+func (s *Scheduler) Submit(j Job) error {
+	_, err := s.enqueue(j)
+	return err
+}
+
+func (s *Scheduler) enqueue(j Job) (<-chan error, error) {
+	now := time.Now()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrStopped
+	}
+	if s.cfg.QueueSize > 0 && s.q.Len() >= s.cfg.QueueSize {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w (%d)", ErrFull, s.cfg.QueueSize)
+	}
+	s.seq++
+	j.seq = s.seq
+	j.airtime = s.airtime(len(j.Frame))
+	j.done = make(chan error, 1)
+	if !j.bulk() && s.cfg.MaxDelay > 0 {
+		j.deadline = now.Add(s.cfg.MaxDelay)
+	}
+	heap.Push(&s.q, &j)
+	s.mu.Unlock()
+	s.notify()
+	return j.done, nil
+}
+
+// 排队中的下行数
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.q.Len()
+}
+
+// 停止调度，排队中的下行返回 ErrStopped
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for s.q.Len() > 0 {
+		heap.Pop(&s.q).(*Job).done <- ErrStopped
+	}
+	s.mu.Unlock()
+	close(s.stop)
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) airtime(n int) time.Duration {
+	d := s.cfg.FrameOverhead
+	if s.cfg.BitRate > 0 {
+		d += time.Duration(n*8) * time.Second / time.Duration(s.cfg.BitRate)
+	}
+	return d
+}
+
+func (s *Scheduler) run() {
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		s.dropExpired(now)
+		var wait time.Duration = -1
+		var j *Job
+		if s.q.Len() > 0 {
+			if wait = s.delay(s.q[0], now); wait <= 0 {
+				j = heap.Pop(&s.q).(*Job)
+				s.consume(j, now)
+			} else if next := s.nextDeadline(); !next.IsZero() && next.Sub(now) < wait {
+				// 到期即返回 ErrDelay，不等到预算恢复
+				wait = next.Sub(now)
+			}
+		}
+		s.mu.Unlock()
+
+		if j != nil {
+			// 空口预算已在出队时扣除；发送及确认等待（如 mqtt5 SendConfirmed、无写超时的 socket）
+			// 放在单独的协程中，不阻塞后续下行尤其是协议应答的调度
+			go func(j *Job) { j.done <- j.Send() }(j)
+			continue
+		}
+		var timer <-chan time.Time
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			timer = t.C
+			select {
+			case <-timer:
+			case <-s.wake:
+			case <-s.stop:
+				t.Stop()
+				return
+			}
+			t.Stop()
+			continue
+		}
+		select {
+		case <-s.wake:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// 超过最长排队时间的下行返回 ErrDelay
+func (s *Scheduler) dropExpired(now time.Time) {
+	for i := 0; i < s.q.Len(); {
+		j := s.q[i]
+		if j.deadline.IsZero() || now.Before(j.deadline) {
+			i++
+			continue
+		}
+		heap.Remove(&s.q, i)
+		log.Printf("⚠ 汇聚节点 %s 空口预算不足，下行 %s 到 %s 排队超过 %s", s.sink, j.ID, j.Eid, s.cfg.MaxDelay)
+		j.done <- fmt.Errorf("%w (%s)", ErrDelay, s.cfg.MaxDelay)
+	}
+}
+
+// 排队下行中最早的排队期限，没有时为零值
+func (s *Scheduler) nextDeadline() time.Time {
+	var next time.Time
+	for _, j := range s.q {
+		if !j.deadline.IsZero() && (next.IsZero() || j.deadline.Before(next)) {
+			next = j.deadline
+		}
+	}
+	return next
+}
+
+// j 还需等待多久才能发送
+func (s *Scheduler) delay(j *Job, now time.Time) time.Duration {
+	var wait time.Duration
+	// 令牌桶
+	if s.cfg.Rate > 0 {
+		s.tokens += now.Sub(s.refilled).Seconds() * s.cfg.Rate
+		if s.tokens > float64(s.cfg.Burst) {
+			s.tokens = float64(s.cfg.Burst)
+		}
+		s.refilled = now
+		if s.tokens < 1 {
+			wait = time.Duration((1 - s.tokens) / s.cfg.Rate * float64(time.Second))
+		}
+	}
+	// 占空比：窗口内空口时长加上本帧不超过预算，窗口为空时总是允许
+	if s.cfg.DutyCycle > 0 && s.cfg.DutyWindow > 0 {
+		cut := now.Add(-s.cfg.DutyWindow)
+		k := 0
+		for k < len(s.usage) && !s.usage[k].at.After(cut) {
+			k++
+		}
+		s.usage = s.usage[k:]
+		budget := time.Duration(s.cfg.DutyCycle * float64(s.cfg.DutyWindow))
+		var used time.Duration
+		for _, u := range s.usage {
+			used += u.d
+		}
+		for _, u := range s.usage {
+			if used+j.airtime <= budget {
+				break
+			}
+			// 等到最早的一帧移出窗口
+			used -= u.d
+			if w := u.at.Add(s.cfg.DutyWindow).Sub(now); w > wait {
+				wait = w
+			}
+		}
+	}
+	// 批量任务间隔
+	if j.bulk() && s.cfg.BulkInterval > 0 && !s.lastBulk.IsZero() {
+		if w := s.lastBulk.Add(s.cfg.BulkInterval).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+func (s *Scheduler) consume(j *Job, now time.Time) {
+	if s.cfg.Rate > 0 {
+		s.tokens--
+	}
+	s.usage = append(s.usage, usage{at: now, d: j.airtime})
+	if j.bulk() {
+		s.lastBulk = now
+	}
+}
+
+// 按优先级从高到低、同优先级先入先出
+type jobHeap []*Job
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, k int) bool {
+	if h[i].Priority != h[k].Priority {
+		return h[i].Priority > h[k].Priority
+	}
+	return h[i].seq < h[k].seq
+}
+func (h jobHeap) Swap(i, k int) { h[i], h[k] = h[k], h[i] }
+func (h *jobHeap) Push(x any)   { *h = append(*h, x.(*Job)) }
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	*h = old[:n-1]
+	return j
+}
@@ -0,0 +1,143 @@
+package airtime
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录各下行的实际发送时刻与顺序
+type recorder struct {
+	mu    sync.Mutex
+	start time.Time
+	ids   []string
+	at    []time.Duration
+}
+
+func newRecorder() *recorder { return &recorder{start: time.Now()} }
+
+func (r *recorder) job(id string, priority int, n int) Job {
+	return Job{
+		ID:       id,
+		Eid:      "SENSOR01",
+		Frame:    make([]byte, n),
+		Priority: priority,
+		Send: func() error {
+			r.mu.Lock()
+			r.ids = append(r.ids, id)
+			r.at = append(r.at, time.Since(r.start))
+			r.mu.Unlock()
+			return nil
+		},
+	}
+}
+
+func (r *recorder) snapshot() ([]string, []time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...), append([]time.Duration(nil), r.at...)
+}
+
+func TestTokenBucket(t *testing.T) {
+	s := New("SINK01", Config{Rate: 10, Burst: 2})
+	defer s.Close()
+	r := newRecorder()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if err := s.Do(r.job(id, 0, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, at := r.snapshot()
+	// 突发 2 帧立即发出，之后每 100ms 补一个令牌
+	if at[1] > 50*time.Millisecond {
+		t.Fatalf("突发内的第 2 帧延迟 %s", at[1])
+	}
+	if at[2] < 80*time.Millisecond || at[3] < 180*time.Millisecond {
+		t.Fatalf("令牌不足时未等待: %v", at)
+	}
+}
+
+func TestDutyCycle(t *testing.T) {
+	// 每帧 50ms 空口，200ms 窗口内预算 100ms
+	s := New("SINK01", Config{DutyCycle: 0.5, DutyWindow: 200 * time.Millisecond, FrameOverhead: 50 * time.Millisecond})
+	defer s.Close()
+	r := newRecorder()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.Do(r.job(id, 0, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, at := r.snapshot()
+	if at[1] > 50*time.Millisecond {
+		t.Fatalf("预算内的第 2 帧延迟 %s", at[1])
+	}
+	// 第 3 帧须等第 1 帧移出窗口
+	if at[2] < 180*time.Millisecond {
+		t.Fatalf("超出占空比仍立即发送: %v", at)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	s := New("SINK01", Config{Rate: 20, Burst: 1})
+	defer s.Close()
+	r := newRecorder()
+	// 先耗尽令牌，其余下行排队等待
+	if err := s.Do(r.job("first", 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, j := range []Job{r.job("bulk", -1, 1), r.job("low1", 0, 1), r.job("low2", 0, 1), r.job("urgent", 10, 1)} {
+		wg.Add(1)
+		done, err := s.enqueue(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() { <-done; wg.Done() }()
+	}
+	wg.Wait()
+	ids, _ := r.snapshot()
+	want := []string{"first", "urgent", "low1", "low2", "bulk"}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("发送顺序 = %v，期望 %v", ids, want)
+		}
+	}
+}
+
+// 阻塞的发送（如等待网关确认）不应挡住后续下行
+func TestBlockingSendDoesNotStall(t *testing.T) {
+	s := New("SINK01", Config{Rate: 100, Burst: 10})
+	defer s.Close()
+	release := make(chan struct{})
+	defer close(release)
+	if err := s.Submit(Job{ID: "slow", Send: func() error { <-release; return nil }}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Do(Job{ID: "ack", Priority: 10, Send: func() error { return nil }}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("应答被阻塞的发送挡住")
+	}
+}
+
+func TestMaxDelay(t *testing.T) {
+	s := New("SINK01", Config{Rate: 1, Burst: 1, MaxDelay: 50 * time.Millisecond})
+	defer s.Close()
+	r := newRecorder()
+	if err := s.Do(r.job("a", 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Do(r.job("b", 0, 1)); !errors.Is(err, ErrDelay) {
+		t.Fatal("超过 MaxDelay 应返回 ErrDelay")
+	}
+	// 批量任务不受 MaxDelay 限制
+	if _, err := s.enqueue(r.job("bulk", -1, 1)); err != nil {
+		t.Fatal(err)
+	}
+}
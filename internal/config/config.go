@@ -6,7 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -46,14 +45,6 @@ type profileYAML struct {
 	DeviceResources []DeviceResource `yaml:"deviceResources"`
 }
 
-var (
-	Mu sync.RWMutex
-	// 存储所有设备的静态资源定义，key 为设备逻辑名称
-	resourcesMap = make(map[string][]DeviceResource)
-	//存储所有设备的运行时资源值，key: 设备名称 → (资源名称 → value)
-	ValuesMap = make(map[string]map[string]interface{})
-)
-
 // 根据 ValueType 将 DefaultValue 字符串转换为对应类型
 func parseDefaultValue(valStr, vt string) interface{} {
 	switch vt {
@@ -65,6 +56,14 @@ func parseDefaultValue(valStr, vt string) interface{} {
 		if u, err := strconv.ParseUint(valStr, 10, 16); err == nil {
 			return uint16(u)
 		}
+	case "Uint32":
+		if u, err := strconv.ParseUint(valStr, 10, 32); err == nil {
+			return uint32(u)
+		}
+	case "Int64":
+		if i, err := strconv.ParseInt(valStr, 10, 64); err == nil {
+			return i
+		}
 	case "Uint8":
 		if u, err := strconv.ParseUint(valStr, 10, 8); err == nil {
 			return uint8(u)
@@ -90,7 +89,7 @@ func parseDefaultValue(valStr, vt string) interface{} {
 // 初始化静态资源定义及默认运行时值：
 // 1. 读取并解析 devices.yaml，获取所有设备条目
 // 2. 遍历每个 entry，根据 ProfileName 加载 Profile 文件，解析 deviceResources
-// 3. 填充全局 maps，并将 DefaultValue 作为初始值写入 valuesMap
+// 3. 写入设备存储，DefaultValue 作为初始值
 func InitDeviceResources(devicesPath, profilesDir string) error {
 	// 读取 devices.yaml
 	raw, err := os.ReadFile(devicesPath)
@@ -102,9 +101,7 @@ func InitDeviceResources(devicesPath, profilesDir string) error {
 	if err := yaml.Unmarshal(raw, &devs); err != nil {
 		return fmt.Errorf("解析 devices.yaml 失败：%w", err)
 	}
-	Mu.Lock()
-	defer Mu.Unlock()
-	// 加载并写入静态资源和默认值表
+	// 加载并写入静态资源和默认值
 	for _, entry := range devs.DeviceList {
 		profileFile := filepath.Join(profilesDir, entry.ProfileName+".yaml")
 		rawProfile, err := os.ReadFile(profileFile)
@@ -115,107 +112,7 @@ func InitDeviceResources(devicesPath, profilesDir string) error {
 		if err := yaml.Unmarshal(rawProfile, &prof); err != nil {
 			return fmt.Errorf("解析 Profile 文件 %s 失败：%w", profileFile, err)
 		}
-		// 保存定义
-		resourcesMap[entry.Name] = prof.DeviceResources
-		// 初始化运行时值为 DefaultValue
-		ValuesMap[entry.Name] = make(map[string]interface{}, len(prof.DeviceResources))
-		for _, dr := range prof.DeviceResources {
-			ValuesMap[entry.Name][dr.Name] = parseDefaultValue(dr.Properties.DefaultValue, dr.Properties.ValueType)
-		}
-	}
-	return nil
-}
-
-// 获取指定设备的资源列表
-// 返回值: []DeviceResource, bool(是否存在)
-func GetDeviceResources(deviceName string) ([]DeviceResource, bool) {
-	Mu.RLock()
-	defer Mu.RUnlock()
-	res, ok := resourcesMap[deviceName]
-	return res, ok
-}
-
-// 写入解析后的单个资源值
-func SetDeviceValue(deviceName, resourceName string, value interface{}) {
-	Mu.Lock()
-	defer Mu.Unlock()
-	if _, ok := ValuesMap[deviceName]; !ok {
-		ValuesMap[deviceName] = make(map[string]interface{})
-	}
-	ValuesMap[deviceName][resourceName] = value
-}
-
-// 获取指定设备的单个资源值
-// 返回值: interface{}, bool(是否存在)
-func GetDeviceValue(deviceName, resourceName string) (interface{}, bool) {
-	Mu.RLock()
-	defer Mu.RUnlock()
-	//  设备是否存在
-	deviceValues, ok := ValuesMap[deviceName]
-	if !ok {
-		return nil, false
-	}
-	// 资源是否存在并返回值
-	value, exists := deviceValues[resourceName]
-	return value, exists
-}
-
-// 获取指定设备的所有运行时资源值
-// 返回值: map[resourceName]value, bool(是否存在)
-func GetDeviceValues(deviceName string) (map[string]interface{}, bool) {
-	Mu.RLock()
-	defer Mu.RUnlock()
-	vals, ok := ValuesMap[deviceName]
-	if !ok {
-		return nil, false
-	}
-
-	copyMap := make(map[string]interface{}, len(vals))
-	for k, v := range vals {
-		copyMap[k] = v
-	}
-	return copyMap, true
-}
-
-// 初始化设备资源并设置正确类型的默认值
-func DeviceInit(deviceName, resourceName, defaultValue, valueType string) error {
-	Mu.Lock()
-	defer Mu.Unlock()
-	// 确保有对应映射
-	if _, exists := ValuesMap[deviceName]; !exists {
-		ValuesMap[deviceName] = make(map[string]interface{})
-	}
-	// 转换默认值
-	parsedValue := parseDefaultValue(defaultValue, valueType)
-	ValuesMap[deviceName][resourceName] = parsedValue
-	return nil
-}
-
-// 删除指定设备的所有运行时值
-func DeleteDeviceValues(deviceName string) error {
-	Mu.Lock()
-	defer Mu.Unlock()
-	// 设备是否存在
-	if _, exists := ValuesMap[deviceName]; !exists {
-		return fmt.Errorf("设备 %s 不存在于运行时值表中", deviceName)
-	}
-	// 删除设备有运行值
-	delete(ValuesMap, deviceName)
-	return nil
-}
-
-// 删除指定设备的所有传感器ID映射
-func DeleteSensorIDMappingsByDevice(deviceName string) error {
-	// 遍历映射，删除所有指向该设备的条目
-	toDelete := make([]string, 0)
-	for sensorID, mappedDeviceName := range SensorIDToDeviceName {
-		if mappedDeviceName == deviceName {
-			toDelete = append(toDelete, sensorID)
-		}
-	}
-	// 删除找到的映射
-	for _, sensorID := range toDelete {
-		delete(SensorIDToDeviceName, sensorID)
+		Devices().SetResources(entry.Name, prof.DeviceResources)
 	}
 	return nil
 }
@@ -0,0 +1,490 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 资源名：设备的 EID，写入时同步更新 EID → 设备名映射
+const EIDResource = "eid"
+
+// 订阅通道缓冲，Subscribe 的订阅消费过慢时丢弃并告警
+const subscriptionBuffer = 64
+
+// 设备资源值的一次变化
+type Change struct {
+	Device   string
+	Resource string // 设备被删除时为空
+	Value    interface{}
+	Old      interface{}
+	Time     time.Time
+	Removed  bool    // 设备被删除
+	Origin   *Origin // 由上行帧解析得到时为来源帧，其余为 nil
+}
+
+// 资源值来源的上行帧
+type Origin struct {
+	Eid        string `json:"eid"`
+	PacketType uint8  `json:"packetType"` // 0 监测、2 告警、4/5 控制响应
+	Fragmented bool   `json:"fragmented,omitempty"`
+	Check      uint16 `json:"crc"`
+}
+
+// 变化订阅，Close 后通道关闭
+type Subscription struct {
+	C <-chan Change
+
+	ch       chan Change
+	device   string
+	resource string
+	store    *DeviceStore
+	once     sync.Once
+
+	// 同步订阅（SubscribeFunc）：在写入方协程中直接回调，不经 ch
+	fn func(Change)
+
+	// 合并订阅（SubscribeLatest）：按（设备, 资源）只保留最新一次变化，由 pump 依次送入 ch
+	latest  bool
+	mu      sync.Mutex
+	pending map[changeKey]Change
+	order   []changeKey
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+type changeKey struct {
+	device   string
+	resource string
+}
+
+// 设备静态资源定义、运行时值及 EID 映射，并发安全
+// 值的变化推送给订阅者，替代轮询
+type DeviceStore struct {
+	mu        sync.RWMutex
+	resources map[string][]DeviceResource       // 设备 → 资源定义
+	values    map[string]map[string]interface{} // 设备 → 资源 → 值
+	updated   map[string]map[string]time.Time   // 由传感器数据更新的时间，默认值不记录
+	eids      map[string]string                 // EID → 设备名
+
+	subMu sync.RWMutex
+	subs  map[*Subscription]struct{}
+}
+
+func NewDeviceStore() *DeviceStore {
+	return &DeviceStore{
+		resources: make(map[string][]DeviceResource),
+		values:    make(map[string]map[string]interface{}),
+		updated:   make(map[string]map[string]time.Time),
+		eids:      make(map[string]string),
+		subs:      make(map[*Subscription]struct{}),
+	}
+}
+
+var devices = NewDeviceStore()
+
+// 服务使用的设备存储
+func Devices() *DeviceStore { return devices }
+
+// 设置设备的资源定义，并把各资源值初始化为 DefaultValue
+func (s *DeviceStore) SetResources(deviceName string, resources []DeviceResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[deviceName] = resources
+	vals := make(map[string]interface{}, len(resources))
+	for _, dr := range resources {
+		vals[dr.Name] = parseDefaultValue(dr.Properties.DefaultValue, dr.Properties.ValueType)
+	}
+	s.values[deviceName] = vals
+	s.mapEIDLocked(deviceName, vals[EIDResource])
+}
+
+// 设备的资源定义
+func (s *DeviceStore) Resources(deviceName string) ([]DeviceResource, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res, ok := s.resources[deviceName]
+	return res, ok
+}
+
+// 把资源值设为默认值，不记更新时间也不通知订阅者
+func (s *DeviceStore) InitDefault(deviceName, resourceName, defaultValue, valueType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := parseDefaultValue(defaultValue, valueType)
+	s.valuesLocked(deviceName)[resourceName] = v
+	if resourceName == EIDResource {
+		s.mapEIDLocked(deviceName, v)
+	}
+}
+
+// 写入传感器数据解析出的资源值
+func (s *DeviceStore) Set(deviceName, resourceName string, value interface{}) {
+	s.SetFrom(deviceName, resourceName, value, nil)
+}
+
+// 同 Set，并在变化事件中带上来源帧
+func (s *DeviceStore) SetFrom(deviceName, resourceName string, value interface{}, origin *Origin) {
+	now := time.Now()
+	s.mu.Lock()
+	vals := s.valuesLocked(deviceName)
+	old := vals[resourceName]
+	vals[resourceName] = value
+	if _, ok := s.updated[deviceName]; !ok {
+		s.updated[deviceName] = make(map[string]time.Time)
+	}
+	s.updated[deviceName][resourceName] = now
+	if resourceName == EIDResource {
+		s.mapEIDLocked(deviceName, value)
+	}
+	s.mu.Unlock()
+	s.publish(Change{Device: deviceName, Resource: resourceName, Value: value, Old: old, Time: now, Origin: origin})
+}
+
+func (s *DeviceStore) valuesLocked(deviceName string) map[string]interface{} {
+	vals, ok := s.values[deviceName]
+	if !ok {
+		vals = make(map[string]interface{})
+		s.values[deviceName] = vals
+	}
+	return vals
+}
+
+// 单个资源值
+func (s *DeviceStore) Get(deviceName, resourceName string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[deviceName][resourceName]
+	return v, ok
+}
+
+// 字符串类型的资源值，类型不符时返回 false
+func (s *DeviceStore) String(deviceName, resourceName string) (string, bool) {
+	v, _ := s.Get(deviceName, resourceName)
+	t, ok := v.(string)
+	return t, ok
+}
+
+// Int64 类型的资源值，类型不符时返回 false
+func (s *DeviceStore) Int64(deviceName, resourceName string) (int64, bool) {
+	v, _ := s.Get(deviceName, resourceName)
+	t, ok := v.(int64)
+	return t, ok
+}
+
+// Uint32 类型的资源值，类型不符时返回 false
+func (s *DeviceStore) Uint32(deviceName, resourceName string) (uint32, bool) {
+	v, _ := s.Get(deviceName, resourceName)
+	t, ok := v.(uint32)
+	return t, ok
+}
+
+// Uint8 类型的资源值，类型不符时返回 false
+func (s *DeviceStore) Uint8(deviceName, resourceName string) (uint8, bool) {
+	v, _ := s.Get(deviceName, resourceName)
+	t, ok := v.(uint8)
+	return t, ok
+}
+
+// 设备全部资源值的副本
+func (s *DeviceStore) Values(deviceName string) (map[string]interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vals, ok := s.values[deviceName]
+	if !ok {
+		return nil, false
+	}
+	out := make(map[string]interface{}, len(vals))
+	for k, v := range vals {
+		out[k] = v
+	}
+	return out, true
+}
+
+// 资源值最近一次由传感器数据更新的时间，从未收到过数据时返回 false
+func (s *DeviceStore) UpdatedAt(deviceName, resourceName string) (time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.updated[deviceName][resourceName]
+	return t, ok
+}
+
+// 有运行时值的设备名，按名称排序
+func (s *DeviceStore) Names() []string {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.values))
+	for name := range s.values {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// 删除设备的运行时值、资源定义和 EID 映射
+func (s *DeviceStore) Delete(deviceName string) error {
+	s.mu.Lock()
+	if _, ok := s.values[deviceName]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("设备 %s 不存在于运行时值表中", deviceName)
+	}
+	delete(s.values, deviceName)
+	delete(s.updated, deviceName)
+	delete(s.resources, deviceName)
+	for eid, name := range s.eids {
+		if name == deviceName {
+			delete(s.eids, eid)
+		}
+	}
+	s.mu.Unlock()
+	s.publish(Change{Device: deviceName, Time: time.Now(), Removed: true})
+	return nil
+}
+
+// 根据 EID 返回逻辑设备名
+func (s *DeviceStore) Lookup(eid string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	name, ok := s.eids[strings.ToUpper(eid)]
+	return name, ok
+}
+
+// 手动添加一条 EID → 设备名映射，已存在时覆盖
+func (s *DeviceStore) MapEID(eid, deviceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eids[strings.ToUpper(eid)] = deviceName
+}
+
+// 删除一条 EID 映射，不存在时返回错误
+func (s *DeviceStore) UnmapEID(eid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	eid = strings.ToUpper(eid)
+	if _, ok := s.eids[eid]; !ok {
+		return fmt.Errorf("未找到 EID %s 的映射", eid)
+	}
+	delete(s.eids, eid)
+	return nil
+}
+
+// EID 映射副本
+func (s *DeviceStore) EIDs() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]string, len(s.eids))
+	for k, v := range s.eids {
+		out[k] = v
+	}
+	return out
+}
+
+// 设备 eid 资源变化时更新映射，去掉该设备的旧 EID
+func (s *DeviceStore) mapEIDLocked(deviceName string, raw interface{}) {
+	var eid string
+	switch v := raw.(type) {
+	case nil:
+		return
+	case string:
+		eid = v
+	case []byte:
+		eid = string(v)
+	default:
+		eid = fmt.Sprint(v)
+	}
+	eid = strings.ToUpper(eid)
+	for k, name := range s.eids {
+		if name == deviceName && k != eid {
+			delete(s.eids, k)
+		}
+	}
+	if eid != "" {
+		s.eids[eid] = deviceName
+	}
+}
+
+// 订阅资源值变化，deviceName、resourceName 为空时匹配全部；设备删除事件总会推送给匹配该设备的订阅
+// 消费过慢、缓冲满时丢弃变化，驱动内部依赖最新值的订阅者应使用 SubscribeLatest
+func (s *DeviceStore) Subscribe(deviceName, resourceName string) *Subscription {
+	ch := make(chan Change, subscriptionBuffer)
+	sub := &Subscription{C: ch, ch: ch, device: deviceName, resource: resourceName, store: s}
+	s.subMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.subMu.Unlock()
+	return sub
+}
+
+// 同 Subscribe，但从不丢弃：消费过慢时同一（设备, 资源）的多次变化合并为最新一次，
+// Old 保留合并前的旧值；设备删除事件取代该设备尚未送出的变化
+func (s *DeviceStore) SubscribeLatest(deviceName, resourceName string) *Subscription {
+	ch := make(chan Change)
+	sub := &Subscription{
+		C: ch, ch: ch, device: deviceName, resource: resourceName, store: s,
+		latest:  true,
+		pending: make(map[changeKey]Change),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	s.subMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.subMu.Unlock()
+	go sub.pump()
+	return sub
+}
+
+// 同步订阅：每次变化都在写入方协程中直接回调 fn，从不丢弃也不合并，C 为 nil。
+// fn 须快速返回且不得写入 DeviceStore；Close 返回后不再回调
+func (s *DeviceStore) SubscribeFunc(deviceName, resourceName string, fn func(Change)) *Subscription {
+	sub := &Subscription{device: deviceName, resource: resourceName, store: s, fn: fn}
+	s.subMu.Lock()
+	s.subs[sub] = struct{}{}
+	s.subMu.Unlock()
+	return sub
+}
+
+// 取消订阅
+func (sub *Subscription) Close() {
+	sub.once.Do(func() {
+		sub.store.subMu.Lock()
+		delete(sub.store.subs, sub)
+		switch {
+		case sub.fn != nil:
+		case sub.latest:
+			close(sub.done) // 由 pump 关闭 ch
+		default:
+			close(sub.ch)
+		}
+		sub.store.subMu.Unlock()
+	})
+}
+
+// 合并一次变化，不阻塞
+func (sub *Subscription) offer(c Change) {
+	k := changeKey{c.Device, c.Resource}
+	sub.mu.Lock()
+	if c.Removed {
+		kept := sub.order[:0]
+		for _, o := range sub.order {
+			if o.device == c.Device {
+				delete(sub.pending, o)
+				continue
+			}
+			kept = append(kept, o)
+		}
+		sub.order = kept
+	}
+	if prev, ok := sub.pending[k]; ok {
+		c.Old = prev.Old
+	} else {
+		sub.order = append(sub.order, k)
+	}
+	sub.pending[k] = c
+	sub.mu.Unlock()
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 按先后依次送出合并后的变化
+func (sub *Subscription) pump() {
+	defer close(sub.ch)
+	for {
+		sub.mu.Lock()
+		if len(sub.order) == 0 {
+			sub.mu.Unlock()
+			select {
+			case <-sub.wake:
+				continue
+			case <-sub.done:
+				return
+			}
+		}
+		k := sub.order[0]
+		sub.order = sub.order[1:]
+		c := sub.pending[k]
+		delete(sub.pending, k)
+		sub.mu.Unlock()
+		select {
+		case sub.ch <- c:
+		case <-sub.done:
+			return
+		}
+	}
+}
+
+func (sub *Subscription) matches(c Change) bool {
+	if sub.device != "" && sub.device != c.Device {
+		return false
+	}
+	return c.Removed || sub.resource == "" || sub.resource == c.Resource
+}
+
+func (s *DeviceStore) publish(c Change) {
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+	for sub := range s.subs {
+		if !sub.matches(c) {
+			continue
+		}
+		if sub.fn != nil {
+			sub.fn(c)
+			continue
+		}
+		if sub.latest {
+			sub.offer(c)
+			continue
+		}
+		select {
+		case sub.ch <- c:
+		default:
+			log.Printf("⚠ 订阅 %s.%s 处理过慢，丢弃变化 %s.%s", sub.device, sub.resource, c.Device, c.Resource)
+		}
+	}
+}
+
+// 由传感器数据更新过的一个资源值，用于落盘后恢复
+type StoredValue struct {
+	Device   string
+	Resource string
+	Value    interface{}
+	Updated  time.Time
+}
+
+// 全部由传感器数据更新过的资源值，不含仍为默认值的资源；按设备、资源名排序
+func (s *DeviceStore) Stored() []StoredValue {
+	s.mu.RLock()
+	var out []StoredValue
+	for dev, res := range s.updated {
+		for name, t := range res {
+			if v, ok := s.values[dev][name]; ok {
+				out = append(out, StoredValue{Device: dev, Resource: name, Value: v, Updated: t})
+			}
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Device != out[j].Device {
+			return out[i].Device < out[j].Device
+		}
+		return out[i].Resource < out[j].Resource
+	})
+	return out
+}
+
+// 恢复一个资源值及其更新时间，不通知订阅者；启动时在在线检查之前调用
+func (s *DeviceStore) Restore(v StoredValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valuesLocked(v.Device)[v.Resource] = v.Value
+	if _, ok := s.updated[v.Device]; !ok {
+		s.updated[v.Device] = make(map[string]time.Time)
+	}
+	s.updated[v.Device][v.Resource] = v.Updated
+	if v.Resource == EIDResource {
+		s.mapEIDLocked(v.Device, v.Value)
+	}
+}
@@ -2,12 +2,9 @@ package config
 
 import "sync"
 
-// 写入通道
-var WriteChan = make(chan []byte, 100)
-
 // topoList 存储最新一批解析出的 NodeTopology 列表
 var (
-	TopoList []NodeTopology
+	topoList []NodeTopology
 	topoMu   sync.RWMutex
 )
 
@@ -15,7 +12,14 @@ var (
 func GetTopoList() []NodeTopology {
 	topoMu.RLock()
 	defer topoMu.RUnlock()
-	cloned := make([]NodeTopology, len(TopoList))
-	copy(cloned, TopoList)
+	cloned := make([]NodeTopology, len(topoList))
+	copy(cloned, topoList)
 	return cloned
 }
+
+// 替换缓存，启动时从快照恢复
+func SetTopoList(list []NodeTopology) {
+	topoMu.Lock()
+	defer topoMu.Unlock()
+	topoList = list
+}
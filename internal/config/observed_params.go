@@ -0,0 +1,49 @@
+package config
+
+import (
+	"sort"
+	"sync"
+)
+
+// 记录每台设备实际上报过的参量，用于自动生成设备 Profile
+// key: 设备名称 → (参量名 → ParamInfo)
+var (
+	observedMu     sync.RWMutex
+	observedParams = make(map[string]map[string]ParamInfo)
+)
+
+// 记录设备上报的参量，首次出现时返回 true
+func RecordObservedParam(deviceName string, info ParamInfo) bool {
+	observedMu.Lock()
+	defer observedMu.Unlock()
+	params, ok := observedParams[deviceName]
+	if !ok {
+		params = make(map[string]ParamInfo)
+		observedParams[deviceName] = params
+	}
+	if _, seen := params[info.Name]; seen {
+		return false
+	}
+	params[info.Name] = info
+	return true
+}
+
+// 获取设备上报过的全部参量，按名称排序
+func GetObservedParams(deviceName string) []ParamInfo {
+	observedMu.RLock()
+	defer observedMu.RUnlock()
+	params := observedParams[deviceName]
+	out := make([]ParamInfo, 0, len(params))
+	for _, info := range params {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// 删除设备的参量记录
+func DeleteObservedParams(deviceName string) {
+	observedMu.Lock()
+	defer observedMu.Unlock()
+	delete(observedParams, deviceName)
+}
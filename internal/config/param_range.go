@@ -0,0 +1,52 @@
+package config
+
+import "math"
+
+// 取值范围，端点包含在内，开区间一端用 ±Inf 表示
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// 是否为范围内的有限值
+func (r Range) Contains(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0) && x >= r.Min && x <= r.Max
+}
+
+var (
+	nonNegative = Range{0, math.Inf(1)}
+	percent     = Range{0, 100}
+)
+
+// 参量的物理合理范围（参量表单位），超出时多为传感器故障或解析错误
+var paramRanges = map[string]Range{
+	"BatteryRemaining": percent,
+	"BatteryVoltage":   nonNegative,
+	"Humidity1":        percent,
+	"SF6Purity":        percent,
+	"BalanceDegree":    percent,
+	"CellSOC":          percent,
+	"CellSOH":          percent,
+	"PowerFactor":      {-1, 1},
+	"Frequency":        nonNegative,
+	"Mass":             nonNegative,
+	"Length":           nonNegative,
+	"Oxygen":           nonNegative,
+	"SF6Moisture":      nonNegative,
+	"SF6O2N2":          nonNegative,
+	// 绝对压力不可能为负，表压可以
+	"SF6AbsolutePressure": nonNegative,
+	// 低于绝对零度
+	"Temperature":          {-273.15, math.Inf(1)},
+	"Temperature1":         {-273.15, math.Inf(1)},
+	"OilTemperature":       {-273.15, math.Inf(1)},
+	"SF6Temperature":       {-273.15, math.Inf(1)},
+	"CellTemperature":      {-273.15, math.Inf(1)},
+	"ACCurrentTemperature": {-273.15, math.Inf(1)},
+}
+
+// 参量的合理范围，未收录时返回 false
+func PlausibleRange(name string) (Range, bool) {
+	r, ok := paramRanges[name]
+	return r, ok
+}
@@ -4,6 +4,7 @@ package config
 import (
 	"encoding/binary"
 	"errors"
+	"sync"
 )
 
 // Entry 表示一个参数在报文中的完整字段（不含后面的 CRC、帧头等）
@@ -18,7 +19,8 @@ type Entry struct {
 
 // 全局表：参数名 → *Entry
 var (
-	table = map[string]*Entry{
+	tableMu sync.RWMutex
+	table   = map[string]*Entry{
 		// 以下举例：假设有两个参数 "Temperature" 和 "Humidity"
 		// 它们在协议里定义的 ParameterType 和 LengthFlag 已知：
 		//  Temperature: 类型码 0x0005, 长度标志 0 → 数据固定 4 字节
@@ -41,8 +43,8 @@ var (
 // 要求 len(value) == entry.length，否则报错；
 // data 会被完整拷贝到内部存储。
 func UpdateData(name string, value []byte) error {
-	Mu.Lock()
-	defer Mu.Unlock()
+	tableMu.Lock()
+	defer tableMu.Unlock()
 
 	e, ok := table[name]
 	if !ok {
@@ -59,8 +61,8 @@ func UpdateData(name string, value []byte) error {
 // GetPacketFields 返回当前全量“头域+数据域”组合后的字节切片副本，map[key]=[]byte{head16_lo, head16_hi, ...data}
 // head16 按小端序存储在前面 2 字节，后面紧跟 data。
 func GetPacketFields() map[string][]byte {
-	Mu.RLock()
-	defer Mu.RUnlock()
+	tableMu.RLock()
+	defer tableMu.RUnlock()
 
 	out := make(map[string][]byte, len(table))
 	for name, e := range table {
@@ -76,8 +78,8 @@ func GetPacketFields() map[string][]byte {
 
 // GetEntryCopy 返回某个参数的当前 Entry 副本，包含 head16、length 和 data 副本
 func GetEntryCopy(name string) (Entry, error) {
-	Mu.RLock()
-	defer Mu.RUnlock()
+	tableMu.RLock()
+	defer tableMu.RUnlock()
 
 	e, ok := table[name]
 	if !ok {
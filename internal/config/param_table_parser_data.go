@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"sync"
 )
 
 type ParamKey struct {
@@ -306,6 +307,23 @@ var paramMap = map[ParamKey]ParamInfo{
 	{0b011, 0b00000101011}: {"CellTemperature", "℃", 4, "float32", parseFloat32},
 }
 
+var (
+	paramByNameOnce sync.Once
+	paramByName     map[string]ParamInfo
+)
+
+// 按参量名查找类型信息，用于核对 Profile 中的单位
+func ParamByName(name string) (ParamInfo, bool) {
+	paramByNameOnce.Do(func() {
+		paramByName = make(map[string]ParamInfo, len(paramMap))
+		for _, info := range paramMap {
+			paramByName[info.Name] = info
+		}
+	})
+	info, ok := paramByName[name]
+	return info, ok
+}
+
 func LookupParamInfo(paramType uint16) (ParamInfo, bool) {
 	feature := byte((paramType >> 11) & 0x07)
 	code := paramType & 0x7FF
@@ -489,10 +507,8 @@ func parseTopo(data []byte) (any, error) {
 		break
 	}
 
-	// 更新全局
-	topoMu.Lock()
-	TopoList = topoList
-	topoMu.Unlock()
+	// 更新缓存
+	SetTopoList(topoList)
 
 	return topoList, nil
 }
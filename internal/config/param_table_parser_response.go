@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,6 +35,11 @@ func (f *Frame) Bytes() []byte {
 	return buf
 }
 
+// 帧的来源信息，随解析出的资源值写入设备存储
+func (f *Frame) Origin() *Origin {
+	return &Origin{Eid: f.SensorID, PacketType: f.PacketType, Fragmented: f.FragInd != 0, Check: f.Check}
+}
+
 type ResponseKey struct {
 	// 控制报文类型：只用低 7 位
 	CtrlType uint8
@@ -41,20 +47,29 @@ type ResponseKey struct {
 	RequestSetFlag bool
 }
 
+// 解析控制报文响应，返回解析出的参量值（参量名 → 值）
+// Report 为 true 时解析结果作为参量读数上报
 type ResponseHandle struct {
-	Parse func(data []byte, frameCtl Frame) error
+	Parse  func(data []byte, frameCtl Frame) (map[string]interface{}, error)
+	Report bool
 }
 
+// CtrlType 与 frameparser 中各构造函数一致：
+// 0x01 通用参数、0x02 监测数据、0x03 告警参数、0x04 时间参数、0x05 传感器 ID、0x06 复位、0x07 传感器请求对时
 var ResponseMap = map[ResponseKey]ResponseHandle{
-	{CtrlType: 0x02, RequestSetFlag: false}: {common_para_response},
-	{CtrlType: 0x02, RequestSetFlag: true}:  {common_para_response},
-	{CtrlType: 0x04, RequestSetFlag: true}:  {timestamp_response},
-	{CtrlType: 0x03, RequestSetFlag: true}:  {timestamp_response},
-	{CtrlType: 0x06, RequestSetFlag: false}: {reset_response},
-	{CtrlType: 0x06, RequestSetFlag: true}:  {reset_response},
-	{CtrlType: 0x04, RequestSetFlag: false}: {timestamp_response},
-	{CtrlType: 0x07, RequestSetFlag: false}: {resetCommands},
-	{CtrlType: 0x07, RequestSetFlag: false}: {resetCommands},
+	{CtrlType: 0x01, RequestSetFlag: false}: {common_para_response, true},
+	{CtrlType: 0x01, RequestSetFlag: true}:  {common_para_response, true},
+	{CtrlType: 0x02, RequestSetFlag: false}: {common_para_response, true},
+	{CtrlType: 0x02, RequestSetFlag: true}:  {common_para_response, true},
+	{CtrlType: 0x03, RequestSetFlag: false}: {alarm_para_response, false},
+	{CtrlType: 0x03, RequestSetFlag: true}:  {alarm_para_response, false},
+	{CtrlType: 0x04, RequestSetFlag: false}: {timestamp_response, false},
+	{CtrlType: 0x04, RequestSetFlag: true}:  {timestamp_response, false},
+	{CtrlType: 0x05, RequestSetFlag: false}: {sensorid_response, false},
+	{CtrlType: 0x05, RequestSetFlag: true}:  {sensorid_response, false},
+	{CtrlType: 0x06, RequestSetFlag: false}: {reset_response, false},
+	{CtrlType: 0x06, RequestSetFlag: true}:  {reset_response, false},
+	{CtrlType: 0x07, RequestSetFlag: false}: {resetCommands, false},
 }
 
 func LookupResponseHandle(head uint8) (ResponseHandle, bool) {
@@ -66,16 +81,37 @@ func LookupResponseHandle(head uint8) (ResponseHandle, bool) {
 }
 
 // ===================== 通用解析函数 =====================
-var Resources1 = make(map[string]interface{})
-var ResourcesFlag bool = false
 
 // 通用参数查询/设置
-func common_para_response(data []byte, frameCtl Frame) error {
+func common_para_response(data []byte, frameCtl Frame) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	parseParamList(data, frameCtl, func(deviceName string, info ParamInfo, val any) {
+		// 写入运行时值表
+		Devices().SetFrom(deviceName, info.Name, val, frameCtl.Origin())
+		RecordObservedParam(deviceName, info)
+		values[info.Name] = val
+
+		log.Printf("✅ 写入值 %s.%s = %v %s", deviceName, info.Name, val, info.Unit)
+	})
+	return values, nil
+}
+
+// 告警参数查询/设置：列表格式同通用参数，每项为参量类型头 + 告警阈值。
+// 阈值不是测量值，不写入运行时值表，只交给等待的命令，键为 <参量名>_alarm
+func alarm_para_response(data []byte, frameCtl Frame) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	parseParamList(data, frameCtl, func(deviceName string, info ParamInfo, val any) {
+		values[info.Name+"_alarm"] = val
+		log.Printf("✅ 告警阈值 %s.%s = %v %s", deviceName, info.Name, val, info.Unit)
+	})
+	return values, nil
+}
+
+// 按 DataLen 逐项解析参数列表（参数头 2 字节：14bit 类型码 + 2bit 长度指示），
+// 解析成功的参量交给 fn；data 末尾 2 字节为 CRC
+func parseParamList(data []byte, frameCtl Frame, fn func(deviceName string, info ParamInfo, val any)) {
 	idx := 0
 	parsed := 0
-	Resources1 = make(map[string]interface{})
-
-	ResourcesFlag = false
 	for parsed < int(frameCtl.DataLen) {
 		// 参数头2字节
 		if idx+2 > len(data)-2 {
@@ -113,7 +149,7 @@ func common_para_response(data []byte, frameCtl Frame) error {
 		valBytes := data[idx : idx+int(dataLen)]
 		idx += int(dataLen)
 
-		deviceName, hasDevice := LookupDeviceName(frameCtl.SensorID)
+		deviceName, hasDevice := Devices().Lookup(frameCtl.SensorID)
 		if !hasDevice {
 			log.Printf("未知 SensorID=%s，跳过本帧", frameCtl.SensorID)
 			continue
@@ -124,11 +160,7 @@ func common_para_response(data []byte, frameCtl Frame) error {
 			if err != nil {
 				log.Printf("❌ 参数 %s.%s 解析失败: %v", deviceName, info.Name, err)
 			} else {
-				// 写入运行时值表
-				SetDeviceValue(deviceName, info.Name, val)
-				Resources1[info.Name] = val
-
-				log.Printf("✅ 写入值 %s.%s = %v %s", deviceName, info.Name, val, info.Unit)
+				fn(deviceName, info, val)
 			}
 		} else {
 			log.Printf("未找到参数类型信息 type=0x%X", paramType)
@@ -136,20 +168,21 @@ func common_para_response(data []byte, frameCtl Frame) error {
 
 		parsed++
 	}
-	ResourcesFlag = true
-	return nil
 }
 
 // 时间参数查询/设置
-func timestamp_response(data []byte, frameCtl Frame) error {
+func timestamp_response(data []byte, frameCtl Frame) (map[string]interface{}, error) {
 
 	// secs := binary.LittleEndian.Uint32(data)
 	// 转换为本地时区时间
 	// t := time.Unix(int64(secs), 0)
-	deviceName, hasDevice := LookupDeviceName(frameCtl.SensorID)
+	deviceName, hasDevice := Devices().Lookup(frameCtl.SensorID)
 	if !hasDevice {
 		log.Printf("未知 SensorID=%s，跳过本帧", frameCtl.SensorID)
 	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("时间响应长度不足: %d", len(data))
+	}
 	timestamp_ctl := "timestamp"
 	log.Printf("data[0] = 0x%02X", data[0]) // %02X 表示两位十六进制，大写
 	secs := binary.LittleEndian.Uint32(data[0:4])
@@ -157,49 +190,65 @@ func timestamp_response(data []byte, frameCtl Frame) error {
 	log.Printf("世纪秒=%d 时间=%s", secs, t.Format("2006-01-02 15:04:05"))
 
 	strVal := strconv.Itoa(int(data[0]))
-	SetDeviceValue(deviceName, timestamp_ctl, strVal)
-	return nil
+	Devices().SetFrom(deviceName, timestamp_ctl, strVal, frameCtl.Origin())
+	return map[string]interface{}{
+		timestamp_ctl: strVal,
+		"seconds":     secs,
+		"time":        t.Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+// 传感器 ID 查询/设置：报文内容为 6 字节 SensorID（设置时为新 ID）
+func sensorid_response(data []byte, frameCtl Frame) (map[string]interface{}, error) {
+	if len(data) < 6 {
+		return nil, fmt.Errorf("传感器 ID 响应长度不足: %d", len(data))
+	}
+	id := strings.ToUpper(hex.EncodeToString(data[:6]))
+	log.Printf("传感器 ID 响应 SensorID=%s → %s", frameCtl.SensorID, id)
+	return map[string]interface{}{"sensorId": id}, nil
 }
 
 // 复位设置
-func reset_response(data []byte, frameCtl Frame) error {
+func reset_response(data []byte, frameCtl Frame) (map[string]interface{}, error) {
 
-	deviceName, hasDevice := LookupDeviceName(frameCtl.SensorID)
+	deviceName, hasDevice := Devices().Lookup(frameCtl.SensorID)
 	if !hasDevice {
 		log.Printf("未知 SensorID=%s，跳过本帧", frameCtl.SensorID)
 	}
+	if len(data) < 1 {
+		return nil, fmt.Errorf("复位响应为空")
+	}
 	reset_ctl := "reset_ctl"
 	strVal := strconv.Itoa(int(data[0]))
-	SetDeviceValue(deviceName, reset_ctl, strVal)
-	return nil
+	Devices().SetFrom(deviceName, reset_ctl, strVal, frameCtl.Origin())
+	return map[string]interface{}{reset_ctl: strVal}, nil
 }
 
-func resetCommands(data []byte, frameCtl Frame) error {
+func resetCommands(data []byte, frameCtl Frame) (map[string]interface{}, error) {
 
-	deviceName, hasDevice := LookupDeviceName(frameCtl.SensorID)
+	deviceName, hasDevice := Devices().Lookup(frameCtl.SensorID)
 	if !hasDevice {
 		log.Printf("未知 SensorID=%s，跳过本帧", frameCtl.SensorID)
 	}
-	eidValue, ok := GetDeviceValue(deviceName, "eid")
+	eidValue, ok := Devices().Get(deviceName, "eid")
 	if !ok {
 		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		return err
+		return nil, err
 	}
 	eidStr, ok := eidValue.(string)
 	if !ok {
 		err := fmt.Errorf("设备 %s 的 EID 类型错误，期望 string，实际 %T", deviceName, eidValue)
-		return err
+		return nil, err
 	}
-	eidStr = "238A0841D828"
-	// 解码成 6 字节
+	// 解码成 6 字节，帧内 SensorID 为目标传感器自身的 ID（《Q/GDW 12184—2021》附录 D）
 	eidBytes, err := hex.DecodeString(eidStr)
 	if err != nil {
 		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		return err
+		return nil, err
 	}
 	if len(eidBytes) != 6 {
 		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		return err
+		return nil, err
 	}
 	var sensorID [6]byte
 	copy(sensorID[:], eidBytes)
@@ -208,8 +257,5 @@ func resetCommands(data []byte, frameCtl Frame) error {
 	ts := uint32(time.Now().In(loc).Unix()) // 当前时间转为世纪秒
 
 	// 发送命令
-	eidStr, _ = eidValue.(string)
-	RestCommandBuildFrame(eidStr, sensorID, 1, ts)
-
-	return nil
+	return nil, RestCommandBuildFrame(eidStr, sensorID, 1, ts)
 }
@@ -3,7 +3,8 @@ package config
 import (
 	"encoding/binary"
 	"fmt"
-	"strings"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
 )
 
 var auchCRCHi = [256]byte{
@@ -59,18 +60,10 @@ func CRC16(data []byte) uint16 {
 }
 
 func SendFrame(dstAddr string, payload []byte) {
-	// 逐字节格式化
-	var parts []string
-	for _, b := range payload {
-		parts = append(parts, fmt.Sprintf("%02X", b))
-	}
-	hexStr := strings.Join(parts, "") // 合并字符串
-	// AT 命令
-	cmd := fmt.Sprintf("\rAT+DTX=%s,%s\r\n", dstAddr, hexStr)
 	// 调试
-	fmt.Printf(">> Sending AT command: %s", cmd)
-	// 发送
-	WriteChan <- []byte(cmd)
+	fmt.Printf(">> Sending frame to %s: % X\n", dstAddr, payload)
+	// 经当前传输下发
+	relay.SendFrame(dstAddr, payload)
 }
 
 func RestCommandBuildFrame(eidStr string, sensorID [6]byte, requestSetFlag byte, timestamp uint32) error {
@@ -0,0 +1,105 @@
+package ctlwait
+
+// 控制报文请求/响应关联：下发前按（传感器 EID, CtrlType）登记等待，
+// 解析到对应的控制报文响应后交给最早登记的等待者，
+// 不同传感器、不同控制类型互不干扰
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 等待超时
+var ErrTimeout = errors.New("等待传感器响应超时")
+
+// 一条控制报文响应
+type Response struct {
+	Eid        string
+	CtrlType   uint8
+	RequestSet bool
+	// 解析出的参量值，参量名 → 值
+	Values map[string]interface{}
+	// 响应解析失败的原因
+	Err error
+}
+
+type key struct {
+	eid      string
+	ctrlType uint8
+}
+
+// 一次等待
+type Waiter struct {
+	key key
+	ch  chan Response
+}
+
+var (
+	mu      sync.Mutex
+	waiters = make(map[key][]*Waiter)
+)
+
+// 登记等待，需在下发之前调用，避免响应先于登记到达
+func Expect(eid string, ctrlType uint8) *Waiter {
+	w := &Waiter{
+		key: key{eid: strings.ToUpper(eid), ctrlType: ctrlType & 0x7F},
+		ch:  make(chan Response, 1),
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	waiters[w.key] = append(waiters[w.key], w)
+	return w
+}
+
+// 等待响应，超时返回 ErrTimeout；无论结果如何都会注销本次等待
+func (w *Waiter) Wait(timeout time.Duration) (Response, error) {
+	defer w.Cancel()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-w.ch:
+		return r, r.Err
+	case <-timer.C:
+		return Response{}, fmt.Errorf("%w: EID=%s CtrlType=0x%02X (%s)", ErrTimeout, w.key.eid, w.key.ctrlType, timeout)
+	}
+}
+
+// 注销等待（例如下发失败时）
+func (w *Waiter) Cancel() {
+	mu.Lock()
+	defer mu.Unlock()
+	list := waiters[w.key]
+	for i, x := range list {
+		if x == w {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(waiters, w.key)
+	} else {
+		waiters[w.key] = list
+	}
+}
+
+// 把响应交给最早登记的等待者，没有等待者时返回 false
+func Deliver(r Response) bool {
+	k := key{eid: strings.ToUpper(r.Eid), ctrlType: r.CtrlType & 0x7F}
+	mu.Lock()
+	list := waiters[k]
+	if len(list) == 0 {
+		mu.Unlock()
+		return false
+	}
+	w := list[0]
+	if len(list) == 1 {
+		delete(waiters, k)
+	} else {
+		waiters[k] = list[1:]
+	}
+	mu.Unlock()
+	w.ch <- r
+	return true
+}
@@ -0,0 +1,216 @@
+package downlink
+
+// 下行投递跟踪：每条下行分配一个 ID（写入信封 CorrelationID），
+// 网关确认、错误及传感器响应按 ID / EID 回填状态，保留最近若干条供查询
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 下行状态，按先后推进；failed 为终态
+const (
+	StatusQueued    = "queued"    // 已受理，等待发送（含断线缓存）
+	StatusSent      = "sent"      // 已交给传输发出
+	StatusAccepted  = "accepted"  // 网关确认已接收
+	StatusResponded = "responded" // 传感器已回复
+	StatusFailed    = "failed"    // 发送失败或网关返回错误
+)
+
+// 状态先后顺序，只允许向后推进
+var statusRank = map[string]int{
+	StatusQueued:    0,
+	StatusSent:      1,
+	StatusAccepted:  2,
+	StatusResponded: 3,
+	StatusFailed:    4,
+}
+
+// 保留的最近下行条数
+const historySize = 512
+
+// 一条下行的跟踪记录
+type Record struct {
+	ID        string    `json:"id"`
+	Eid       string    `json:"eid"`
+	FrameType int       `json:"frameType"` // 帧头中的报文类型，帧过短时为 -1
+	Transport string    `json:"transport"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+}
+
+var (
+	mu      sync.Mutex
+	records = make(map[string]*Record)
+	order   []string // 按创建先后，超过 historySize 时淘汰最早的
+)
+
+// 登记一条下行，返回跟踪 ID，状态为 queued
+func Track(eid string, frame []byte, transportName string) string {
+	now := time.Now()
+	r := &Record{
+		ID:        uuid.NewString(),
+		Eid:       strings.ToUpper(eid),
+		FrameType: -1,
+		Transport: transportName,
+		Status:    StatusQueued,
+		Created:   now,
+		Updated:   now,
+	}
+	if len(frame) > 6 {
+		r.FrameType = int(frame[6] & 0x07)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	addLocked(r)
+	return r.ID
+}
+
+// 恢复重启前的跟踪记录，已存在时忽略
+func Restore(r Record) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := records[r.ID]; ok {
+		return
+	}
+	addLocked(&r)
+}
+
+func addLocked(r *Record) {
+	records[r.ID] = r
+	order = append(order, r.ID)
+	if n := len(order) - historySize; n > 0 {
+		for _, id := range order[:n] {
+			delete(records, id)
+		}
+		order = append([]string(nil), order[n:]...)
+	}
+}
+
+// 推进状态；不允许回退，已失败或已响应的记录不再变化
+func Update(id, status string, cause error) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	r, ok := records[id]
+	if !ok {
+		return false
+	}
+	advance(r, status, cause)
+	return true
+}
+
+func advance(r *Record, status string, cause error) {
+	if r.Status == StatusFailed || r.Status == StatusResponded {
+		return
+	}
+	if statusRank[status] <= statusRank[r.Status] {
+		return
+	}
+	r.Status = status
+	if cause != nil {
+		r.Error = cause.Error()
+	}
+	r.Updated = time.Now()
+}
+
+// 标记失败
+func Fail(id string, cause error) {
+	Update(id, StatusFailed, cause)
+}
+
+// 网关确认：errorCode 为 0 表示已接收，否则失败
+// 返回 id 是否对应已跟踪的下行
+func Ack(id string, errorCode int) bool {
+	if errorCode == 0 {
+		return Update(id, StatusAccepted, nil)
+	}
+	return Update(id, StatusFailed, fmt.Errorf("网关返回 errorCode=%d", errorCode))
+}
+
+// 传感器回复：把发往 eid、报文类型为 frameType 且尚未完成的下行标记为已响应
+func Responded(eid string, frameType int) int {
+	eid = strings.ToUpper(eid)
+	mu.Lock()
+	defer mu.Unlock()
+	n := 0
+	for _, r := range records {
+		if r.Eid != eid || r.FrameType != frameType {
+			continue
+		}
+		if r.Status == StatusSent || r.Status == StatusAccepted {
+			advance(r, StatusResponded, nil)
+			n++
+		}
+	}
+	return n
+}
+
+// 按 ID 查询
+func Get(id string) (Record, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	r, ok := records[id]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+// 查询条件，空值表示不限
+type Filter struct {
+	Eid    string
+	Status string
+	Limit  int
+}
+
+// 最近的下行，按创建时间倒序
+func Recent(f Filter) []Record {
+	eid := strings.ToUpper(f.Eid)
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Record, 0, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		r := records[order[i]]
+		if eid != "" && r.Eid != eid {
+			continue
+		}
+		if f.Status != "" && r.Status != f.Status {
+			continue
+		}
+		out = append(out, *r)
+		if f.Limit > 0 && len(out) >= f.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// 各状态条数
+func Summary() map[string]int {
+	mu.Lock()
+	defer mu.Unlock()
+	s := make(map[string]int, len(statusRank))
+	for st := range statusRank {
+		s[st] = 0
+	}
+	for _, r := range records {
+		s[r.Status]++
+	}
+	return s
+}
+
+// 合法的状态取值，供接口校验
+func Statuses() []string {
+	out := make([]string, 0, len(statusRank))
+	for st := range statusRank {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return statusRank[out[i]] < statusRank[out[j]] })
+	return out
+}
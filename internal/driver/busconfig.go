@@ -0,0 +1,131 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+
+	bootstrapConfig "github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/config"
+	"github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/environment"
+	"github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/file"
+	"github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/flags"
+	bootstrapInterfaces "github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/interfaces"
+	"github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/secret"
+	"github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/utils"
+	"github.com/edgexfoundry/go-mod-bootstrap/v4/config"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"gopkg.in/yaml.v3"
+)
+
+// 公共配置中的分段，与 go-mod-bootstrap 一致
+const (
+	allServicesSection    = "all-services"
+	deviceServicesSection = "device-services"
+)
+
+// 只关心 MessageBus 段
+type messageBusSection struct {
+	MessageBus config.MessageBusInfo
+}
+
+// 按 SDK 启动时相同的来源与顺序读取 MessageBus 配置，只读，不向配置中心写入：
+// 配置中心模式依次取 all-services、device-services 公共配置及本服务私有配置；
+// 文件模式依次取 -cc 公共配置文件及本服务配置文件，再叠加环境变量覆盖
+func (d *WireSinkDriver) loadMessageBusInfo() (config.MessageBusInfo, error) {
+	var section messageBusSection
+
+	// 与 SDK 相同的命令行参数，-i/--instance 只为能通过解析
+	var instance string
+	f := flags.New()
+	f.FlagSet.StringVar(&instance, "instance", "", "")
+	f.FlagSet.StringVar(&instance, "i", "", "")
+	f.Parse(append([]string(nil), os.Args[1:]...))
+
+	envVars := environment.NewVariables(d.lc)
+	providerInfo, err := bootstrapConfig.NewProviderInfo(envVars, f.ConfigProviderUrl())
+	if err != nil {
+		return section.MessageBus, fmt.Errorf("读取配置中心参数失败: %w", err)
+	}
+
+	if providerInfo.UseProvider() {
+		if sp, ok := d.sdk.SecretProvider().(bootstrapInterfaces.SecretProviderExt); ok {
+			providerInfo.SetAuthInjector(secret.NewJWTSecretProvider(sp))
+		}
+		keys := []string{
+			utils.BuildBaseKey(common.CoreCommonConfigServiceKey, allServicesSection),
+			utils.BuildBaseKey(common.CoreCommonConfigServiceKey, deviceServicesSection),
+			d.sdk.Name(),
+		}
+		for _, key := range keys {
+			if err := d.loadProviderSection(providerInfo, key, &section); err != nil {
+				return section.MessageBus, err
+			}
+		}
+	} else {
+		if name := environment.GetCommonConfigFileName(d.lc, f.CommonConfig()); name != "" {
+			commonCfg, err := d.loadYamlFile(name)
+			if err != nil {
+				return section.MessageBus, err
+			}
+			for _, key := range []string{allServicesSection, deviceServicesSection} {
+				m, _ := commonCfg[key].(map[string]any)
+				if err := utils.ConvertFromMap(m, &section); err != nil {
+					return section.MessageBus, fmt.Errorf("解析公共配置 %s 失败: %w", key, err)
+				}
+			}
+		}
+		private, err := d.loadYamlFile(bootstrapConfig.GetConfigFileLocation(d.lc, f))
+		if err != nil {
+			return section.MessageBus, err
+		}
+		if err := utils.ConvertFromMap(private, &section); err != nil {
+			return section.MessageBus, fmt.Errorf("解析服务配置失败: %w", err)
+		}
+		if _, err := envVars.OverrideConfiguration(&section); err != nil {
+			return section.MessageBus, fmt.Errorf("应用环境变量覆盖失败: %w", err)
+		}
+	}
+
+	info := section.MessageBus
+	if f.InDevMode() {
+		info.Host = "localhost"
+	}
+	if info.Disabled {
+		return info, fmt.Errorf("MessageBus 已禁用（MessageBus.Disabled=true）")
+	}
+	if info.Type == "" || info.Host == "" || info.Port == 0 {
+		return info, fmt.Errorf("MessageBus 配置不完整: Type=%q Host=%q Port=%d", info.Type, info.Host, info.Port)
+	}
+	return info, nil
+}
+
+// 从配置中心读取一个配置段叠加到 section，不存在的段跳过
+func (d *WireSinkDriver) loadProviderSection(providerInfo *bootstrapConfig.ProviderInfo, key string, section *messageBusSection) error {
+	client, err := bootstrapConfig.CreateProviderClient(d.lc, key, common.ConfigStemDevice, providerInfo.ServiceConfig())
+	if err != nil {
+		return fmt.Errorf("创建配置中心客户端 %s 失败: %w", key, err)
+	}
+	exists, err := client.HasConfiguration()
+	if err != nil {
+		return fmt.Errorf("查询配置中心 %s 失败: %w", key, err)
+	}
+	if !exists {
+		return nil
+	}
+	// 只覆盖该段中存在的键
+	if _, err := client.GetConfiguration(section); err != nil {
+		return fmt.Errorf("读取配置中心 %s 失败: %w", key, err)
+	}
+	return nil
+}
+
+func (d *WireSinkDriver) loadYamlFile(path string) (map[string]any, error) {
+	contents, err := file.Load(path, d.sdk.SecretProvider(), d.lc)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+	}
+	data := make(map[string]any)
+	if err := yaml.Unmarshal(contents, &data); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return data, nil
+}
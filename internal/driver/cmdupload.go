@@ -14,6 +14,17 @@ func (d *WireSinkDriver) AsyncReporting(deviceName string, sourceName string, va
 		d.lc.Infof("AsyncReporting: 没有要上报的值")
 		return
 	}
+	// 发现 Profile 未覆盖的参量时自动生成 Profile
+	d.checkUncoveredParams(deviceName)
+	// 按 Profile 中的 enum / bit / bits 属性补充状态参量的可读标签和按位布尔值
+	d.addDerivedValues(deviceName, values)
+	// 按资源的 scale / offset / unit 属性换算为工程量，并检查合理范围及 Profile minimum/maximum
+	values, qualities := d.convertValuesChecked(deviceName, values)
+	// 按资源的死区、最小间隔、心跳属性去掉未明显变化的值（以换算后的值比较）
+	if values = d.filterReport(deviceName, values); len(values) == 0 {
+		d.lc.Debugf("AsyncReporting: 设备 %s 的值均未明显变化，跳过上报", deviceName)
+		return
+	}
 
 	var cvs []*dsModels.CommandValue
 	origin := time.Now().UnixNano()
@@ -29,12 +40,20 @@ func (d *WireSinkDriver) AsyncReporting(deviceName string, sourceName string, va
 			cv, err = dsModels.NewCommandValue(name, common.ValueTypeInt32, v)
 		case int64:
 			cv, err = dsModels.NewCommandValue(name, common.ValueTypeInt64, v)
+		case uint8:
+			cv, err = dsModels.NewCommandValue(name, common.ValueTypeUint8, v)
+		case uint16:
+			cv, err = dsModels.NewCommandValue(name, common.ValueTypeUint16, v)
+		case uint32:
+			cv, err = dsModels.NewCommandValue(name, common.ValueTypeUint32, v)
 		case float32:
 			cv, err = dsModels.NewCommandValue(name, common.ValueTypeFloat32, v)
 		case float64:
 			cv, err = dsModels.NewCommandValue(name, common.ValueTypeFloat64, v)
 		case string:
 			cv, err = dsModels.NewCommandValue(name, common.ValueTypeString, v)
+		case bool:
+			cv, err = dsModels.NewCommandValue(name, common.ValueTypeBool, v)
 		default:
 			d.lc.Infof("不支持的类型: %T", v)
 			continue
@@ -45,6 +64,7 @@ func (d *WireSinkDriver) AsyncReporting(deviceName string, sourceName string, va
 			continue
 		}
 		cv.Origin = origin
+		markQuality(cv, qualities[name])
 		cvs = append(cvs, cv)
 	}
 
@@ -53,6 +73,15 @@ func (d *WireSinkDriver) AsyncReporting(deviceName string, sourceName string, va
 		return
 	}
 
+	// 启用上报缓冲时先落盘，由 drainReadings 按顺序交给 SDK，core 不可达时不阻塞解析协程
+	if d.readings != nil {
+		err := d.readings.Push(toReading(deviceName, sourceName, origin, cvs, d.lc.Errorf))
+		if err == nil {
+			return
+		}
+		d.lc.Errorf("写入上报缓冲失败，直接上报: %v", err)
+	}
+
 	// 封装 AsyncValues
 	asyncValues := &dsModels.AsyncValues{
 		DeviceName:    deviceName,
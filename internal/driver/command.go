@@ -1,40 +1,86 @@
 package driver
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"time"
 
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
 	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/ctlwait"
 	"github.com/linjuya-lu/device-wiresink-go/internal/frameparser"
+	"github.com/linjuya-lu/device-wiresink-go/internal/lifecycle"
 	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
 )
 
-func (d *WireSinkDriver) handleTimeParameterSet(deviceName string) error {
-	d.lc.Infof("开始处理时间设置命令: %s", deviceName)
-	// 获取 EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
+// 资源属性：读该资源时同步执行 query 指定的查询命令
+const queryAttribute = "query"
+
+// 命令暂存时返回的值
+const (
+	heldKey       = "held"
+	queuedKey     = "queued"
+	downlinkIdKey = "downlinkId"
+)
+
+// 控制命令及其下行优先级，event 非空时下发前驱动生命周期状态机
+type controlCommand struct {
+	run      func(deviceName string, priority int) (map[string]interface{}, error)
+	priority int
+	event    lifecycle.Event
+}
+
+// 写资源名 → 控制命令，均等待传感器响应
+func (d *WireSinkDriver) controlCommands() map[string]controlCommand {
+	return map[string]controlCommand{
+		"Time_Parameter_Query":    {d.handleTimeParameterQuery, relay.PriorityRoutine, ""},
+		"Time_Parameter_Set":      {d.handleTimeParameterSet, relay.PrioritySetting, lifecycle.EventReconfigure},
+		"Reset_Set":               {d.handleResetCommand, relay.PriorityUrgent, lifecycle.EventReset},
+		"ID_Query":                {d.handleIdQuery, relay.PriorityRoutine, ""},
+		"General_Parameter_Query": {d.handleGeneParaQuery, relay.PriorityRoutine, ""},
+		"Alarm_Parameter_Query":   {d.handleIdAlarmParaQuery, relay.PriorityUrgent, ""},
+		"Monitoring_Data_Query":   {d.handleIdMoniDataQuery, relay.PriorityRoutine, ""},
 	}
+}
 
-	eidStr := "238A0841D828"
-	// 解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+// 执行控制命令
+func (c controlCommand) call(deviceName string, priority int) (map[string]interface{}, error) {
+	if c.event != "" {
+		lifecycle.Fire(deviceName, c.event, "")
+	}
+	return c.run(deviceName, priority)
+}
+
+// 执行查询命令，把传感器响应作为 Object 值返回
+func (d *WireSinkDriver) readQueryResponse(deviceName, resName, query string) (*dsModels.CommandValue, error) {
+	cmd, ok := d.controlCommands()[query]
+	if !ok {
+		return nil, fmt.Errorf("资源 %s 的 query 属性 %q 不是支持的命令", resName, query)
+	}
+	values, err := cmd.call(deviceName, cmd.priority)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
+		return nil, err
 	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
-		return err
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	values = d.convertValuesForRead(deviceName, values)
+	cv, err := dsModels.NewCommandValue(resName, common.ValueTypeObject, values)
+	if err != nil {
+		return nil, fmt.Errorf("NewCommandValue 失败: %w", err)
+	}
+	return cv, nil
+}
+
+func (d *WireSinkDriver) handleTimeParameterSet(deviceName string, priority int) (map[string]interface{}, error) {
+	d.lc.Infof("开始处理时间设置命令: %s", deviceName)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
+	if err != nil {
+		return nil, err
 	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	// 构建复位帧
 	loc := time.FixedZone("UTC-0", 0) // UTC
 	ts := uint32(time.Now().In(loc).Unix())
@@ -43,262 +89,261 @@ func (d *WireSinkDriver) handleTimeParameterSet(deviceName string) error {
 	reqFrame, _ := frameparser.BuildTimeParamFrame(sensorID, 1, ts)
 
 	// 发送命令
-	eidStr, _ = eidValue.(string)
-	relay.SendFrame(eidStr, reqFrame)
-	d.lc.Infof("已发送时间设置命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	return d.sendControl(deviceName, eidStr, reqFrame, "时间设置", priority)
 }
 
-func (d *WireSinkDriver) handleResetCommand(deviceName string) error {
+func (d *WireSinkDriver) handleResetCommand(deviceName string, priority int) (map[string]interface{}, error) {
 	d.lc.Infof("开始处理复位命令: %s", deviceName)
-	// 获取EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
-	}
-
-	eidStr := "238A0841D828"
-	// 解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
-	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
-		return err
+		return nil, err
 	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	// 构建复位帧
 	reqFrame, _ := frameparser.BuildResetRequest(sensorID)
 	// 发送命令
-	eidStr, _ = eidValue.(string)
-
-	relay.SendFrame(eidStr, reqFrame)
-	d.lc.Infof("已发送复位命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	return d.sendControl(deviceName, eidStr, reqFrame, "复位", priority)
 }
 
-func (d *WireSinkDriver) handleTimeParameterQuery(deviceName string) error {
+func (d *WireSinkDriver) handleTimeParameterQuery(deviceName string, priority int) (map[string]interface{}, error) {
 	d.lc.Infof("开始处理时间参数查询命令: %s", deviceName)
 
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
-	}
-
-	eidStr := "238A0841D828"
-	// 解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
-	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
-		return err
+		return nil, err
 	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	// 构建复位帧
 	reqFrame, _ := frameparser.BuildTimeParamFrame(sensorID, 0, 0)
 	// 发送命令
-	eidStr, _ = eidValue.(string)
-	relay.SendFrame(eidStr, reqFrame)
-	d.lc.Infof("已发送时间参数查询命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	return d.sendControl(deviceName, eidStr, reqFrame, "时间参数查询", priority)
 }
 
-func (d *WireSinkDriver) handleIdQuery(deviceName string) error {
+func (d *WireSinkDriver) handleIdQuery(deviceName string, priority int) (map[string]interface{}, error) {
 	d.lc.Infof("开始处理EID查询命令: %s", deviceName)
-	// 获取EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
-	}
-
-	eidStr := "238A0841D828"
-	// 解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
+		return nil, err
 	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
-		return err
-	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	//构建ID查询帧
 	frame, err := frameparser.BuildSensorIDFrame(sensorID, 0, [6]byte{})
 	if err != nil {
-		return fmt.Errorf("构造传感器ID查询帧失败: %w", err)
+		return nil, fmt.Errorf("构造传感器ID查询帧失败: %w", err)
 	}
-	//发送命令
-	eidStr, _ = eidValue.(string)
-	relay.SendFrame(eidStr, frame)
-	d.lc.Infof("已发送EID查询命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	// 发送命令
+	return d.sendControl(deviceName, eidStr, frame, "EID查询", priority)
 }
 
-func (d *WireSinkDriver) handleIdMoniDataQuery(deviceName string) error {
+func (d *WireSinkDriver) handleIdMoniDataQuery(deviceName string, priority int) (map[string]interface{}, error) {
 	d.lc.Infof("开始处理检测数据查询命令: %s", deviceName)
-	// 获取EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
-	}
-
-	eidStr := "238A0841D828"
-	// 解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
-	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
-		return err
+		return nil, err
 	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	//构建ID查询帧
 	frame, err := frameparser.BuildMonitoringDataQueryFrame(sensorID)
 	if err != nil {
-		return fmt.Errorf("构造全部通用参数查询失败: %w", err)
+		return nil, fmt.Errorf("构造全部通用参数查询失败: %w", err)
 	}
-	eidStr, _ = eidValue.(string)
 	//发送命令
-	relay.SendFrame(eidStr, frame)
-	d.lc.Infof("已发送检测数据查询命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	return d.sendControl(deviceName, eidStr, frame, "检测数据查询", priority)
 }
 
-func (d *WireSinkDriver) handleIdAlarmParaQuery(deviceName string) error {
+func (d *WireSinkDriver) handleIdAlarmParaQuery(deviceName string, priority int) (map[string]interface{}, error) {
 	d.lc.Infof("开始处理告警参数查询命令: %s", deviceName)
-	// 获取EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
-	}
-
-	eidStr := "238A0841D828"
-	//解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
+		return nil, err
 	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
-		return err
-	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	// 构建ID查询帧
 	frame, err := frameparser.BuildAlarmParameterQueryFrame(sensorID)
 	if err != nil {
-		return fmt.Errorf("构造q全部通用参数查询失败: %w", err)
+		return nil, fmt.Errorf("构造q全部通用参数查询失败: %w", err)
 	}
 	// 发送命令
-	eidStr, _ = eidValue.(string)
-	relay.SendFrame(eidStr, frame)
-	d.lc.Infof("已发送告警参数查询命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	return d.sendControl(deviceName, eidStr, frame, "告警参数查询", priority)
 }
 
-func (d *WireSinkDriver) handleGeneParaQuery(deviceName string) error {
+func (d *WireSinkDriver) handleGeneParaQuery(deviceName string, priority int) (map[string]interface{}, error) {
 	d.lc.Infof("开始处理通用参数查询命令: %s", deviceName)
-	// 获取EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
-	if !ok {
-		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
-		d.lc.Error(err.Error())
-		return err
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
+	if err != nil {
+		return nil, err
 	}
-
-	eidStr := "238A0841D828"
-	//解码成 6 字节
-	eidBytes, err := hex.DecodeString(eidStr)
+	//构建ID查询帧
+	frame, err := frameparser.BuildParameterQueryFrame(sensorID)
 	if err != nil {
-		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
-		d.lc.Error(err.Error())
-		return err
+		return nil, fmt.Errorf("构造q全部通用参数查询失败: %w", err)
 	}
-	if len(eidBytes) != 6 {
-		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
-		d.lc.Error(err.Error())
+	// 发送命令
+	return d.sendControl(deviceName, eidStr, frame, "通用参数查询", priority)
+}
+
+func (d *WireSinkDriver) handleRouterParameterQuery(deviceName string) error {
+	d.lc.Infof("开始处拓扑查询命令: %s", deviceName)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
+	if err != nil {
 		return err
 	}
-	var sensorID [6]byte
-	copy(sensorID[:], eidBytes)
 	//构建ID查询帧
-	frame, err := frameparser.BuildParameterQueryFrame(sensorID)
+	frame, err := frameparser.BuildGeneralParamQueryFrame(sensorID, 0x0800)
 	if err != nil {
-		return fmt.Errorf("构造q全部通用参数查询失败: %w", err)
+		return fmt.Errorf("构造拓扑查询失败: %w", err)
 	}
 	//发送命令
-	eidStr, _ = eidValue.(string)
-	relay.SendFrame(eidStr, frame)
-	d.lc.Infof("已发送通用参数查询命令到设备 %s (EID: %s)", deviceName, eidStr)
+	res, err := relay.Deliver(eidStr, frame, relay.Options{Confirm: true})
+	if res.Queued {
+		d.lc.Warnf("传输未连接，拓扑查询命令已缓存待补发: 设备 %s (EID: %s)", deviceName, eidStr)
+		return nil
+	}
+	if err != nil {
+		return d.commandSendFailed(deviceName, eidStr, err)
+	}
+	d.lc.Infof("已发送拓扑查询命令到设备 %s (EID: %s)", deviceName, eidStr)
 	return nil
 }
 
-func (d *WireSinkDriver) handleRouterParameterQuery(deviceName string) error {
-	d.lc.Infof("开始处拓扑查询命令: %s", deviceName)
-	// 获取EID
-	eidValue, ok := config.GetDeviceValue(deviceName, "eid")
+// 写换算资源对应的参数设置命令，与其他设置命令一样驱动生命周期状态机；
+// 只接受参数表中可设置的参量，值在下发前编码校验
+func (d *WireSinkDriver) parameterSetCommand(resName string, raw interface{}) (controlCommand, error) {
+	entry, err := config.GetEntryCopy(resName)
+	if err != nil {
+		return controlCommand{}, fmt.Errorf("资源 %s 不是可设置的参数，拒绝写入", resName)
+	}
+	data, err := encodeParamValue(raw, entry.Length)
+	if err != nil {
+		return controlCommand{}, fmt.Errorf("资源 %s: %w", resName, err)
+	}
+	return controlCommand{
+		run: func(deviceName string, priority int) (map[string]interface{}, error) {
+			return d.handleParameterSet(deviceName, resName, data, priority)
+		},
+		priority: relay.PrioritySetting,
+		event:    lifecycle.EventReconfigure,
+	}, nil
+}
+
+// 以通用参数设置报文下发一个参量已编码的值
+func (d *WireSinkDriver) handleParameterSet(deviceName, resName string, data []byte, priority int) (map[string]interface{}, error) {
+	d.lc.Infof("开始处理参数设置命令: %s.%s = % X", deviceName, resName, data)
+	eidStr, sensorID, err := d.deviceSensorID(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	reqFrame, err := frameparser.BuildGeneralParamFrame(sensorID, 1, []string{resName}, map[string][]byte{resName: data})
+	if err != nil {
+		return nil, fmt.Errorf("构建参数设置帧失败: %w", err)
+	}
+	return d.sendControl(deviceName, eidStr, reqFrame, resName+"参数设置", priority)
+}
+
+// 参量值按小端编码为 n 字节，与解析时的字节序一致
+func encodeParamValue(val interface{}, n int) ([]byte, error) {
+	b := make([]byte, 4)
+	size := 0
+	switch x := val.(type) {
+	case float32:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(x))
+		size = 4
+	case float64:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(x)))
+		size = 4
+	case uint8:
+		b[0], size = x, 1
+	case int8:
+		b[0], size = byte(x), 1
+	case uint16:
+		binary.LittleEndian.PutUint16(b, x)
+		size = 2
+	case int16:
+		binary.LittleEndian.PutUint16(b, uint16(x))
+		size = 2
+	case uint32:
+		binary.LittleEndian.PutUint32(b, x)
+		size = 4
+	case int32:
+		binary.LittleEndian.PutUint32(b, uint32(x))
+		size = 4
+	default:
+		return nil, fmt.Errorf("不支持下发 %T 类型的值", val)
+	}
+	if size != n {
+		return nil, fmt.Errorf("值 %v 编码为 %d 字节，参数需要 %d 字节", val, size, n)
+	}
+	return b[:size], nil
+}
+
+// 下发控制报文并等待传感器响应：按（EID, CtrlType）关联，
+// 超时时间取 WireSink.Writable.CommandTimeout，返回解析出的响应参量
+func (d *WireSinkDriver) sendControl(deviceName, eidStr string, frame []byte, desc string, priority int) (map[string]interface{}, error) {
+	if len(frame) < 8 {
+		return nil, fmt.Errorf("%s控制帧长度不足: %d", desc, len(frame))
+	}
+	// 控制字段：CtrlType(7b) | RequestSetFlag(1b)
+	ctrlType := frame[7] >> 1
+	w := ctlwait.Expect(eidStr, ctrlType)
+	res, err := relay.Deliver(eidStr, frame, relay.Options{Priority: priority, Confirm: true})
+	if res.Queued {
+		// 传输断线，帧在断线缓存中待补发，此时等待响应必然超时，结果经下行跟踪查询
+		w.Cancel()
+		d.lc.Warnf("传输未连接，发往设备 %s (EID: %s) 的%s命令已缓存待补发: %s", deviceName, eidStr, desc, res.ID)
+		return map[string]interface{}{queuedKey: true, downlinkIdKey: res.ID}, nil
+	}
+	if err != nil {
+		w.Cancel()
+		return nil, d.commandSendFailed(deviceName, eidStr, err)
+	}
+	if res.Held {
+		// 休眠传感器上线后才下发，不等待响应，结果经下行跟踪查询
+		w.Cancel()
+		d.lc.Infof("设备 %s (EID: %s) 休眠中，%s命令已暂存: %s", deviceName, eidStr, desc, res.ID)
+		return map[string]interface{}{heldKey: true, downlinkIdKey: res.ID}, nil
+	}
+	d.lc.Infof("已发送%s命令到设备 %s (EID: %s)，等待响应", desc, deviceName, eidStr)
+	timeout, _ := parsePositiveDuration(d.config().WireSink.Writable.CommandTimeout)
+	resp, err := w.Wait(timeout)
+	if err != nil {
+		err = fmt.Errorf("设备 %s %s失败: %w", deviceName, desc, err)
+		d.lc.Error(err.Error())
+		return nil, err
+	}
+	d.lc.Infof("设备 %s %s已响应: %v", deviceName, desc, resp.Values)
+	return resp.Values, nil
+}
+
+// 命令下行失败（含网关拒绝）：包装错误并记录
+func (d *WireSinkDriver) commandSendFailed(deviceName, eidStr string, err error) error {
+	err = fmt.Errorf("下发到设备 %s (EID: %s) 失败: %w", deviceName, eidStr, err)
+	d.lc.Error(err.Error())
+	return err
+}
+
+// 取设备的 eid 协议属性，返回 EID 及其解码出的帧内 6 字节 SensorID
+func (d *WireSinkDriver) deviceSensorID(deviceName string) (string, [6]byte, error) {
+	var sensorID [6]byte
+	eidValue, ok := config.Devices().Get(deviceName, "eid")
 	if !ok {
 		err := fmt.Errorf("设备 %s 的 EID 未初始化", deviceName)
 		d.lc.Error(err.Error())
-		return err
+		return "", sensorID, err
 	}
-
-	eidStr := "238A0841D828"
-	// 解码成 6 字节
+	eidStr, ok := eidValue.(string)
+	if !ok {
+		err := fmt.Errorf("设备 %s 的 EID 类型错误，期望 string，实际 %T", deviceName, eidValue)
+		d.lc.Error(err.Error())
+		return "", sensorID, err
+	}
+	// 《Q/GDW 12184—2021》附录 D：帧首 6 字节 SensorID 为目标传感器自身的 ID，
+	// 传感器只接收 SensorID 与自身相符的下行
 	eidBytes, err := hex.DecodeString(eidStr)
 	if err != nil {
 		err = fmt.Errorf("EID[%s] 转十六进制失败: %w", eidStr, err)
 		d.lc.Error(err.Error())
-		return err
+		return "", sensorID, err
 	}
 	if len(eidBytes) != 6 {
 		err = fmt.Errorf("EID 长度不对，期望 6 字节，实际 %d 字节", len(eidBytes))
 		d.lc.Error(err.Error())
-		return err
+		return "", sensorID, err
 	}
-	var sensorID [6]byte
 	copy(sensorID[:], eidBytes)
-	//构建ID查询帧
-	frame, err := frameparser.BuildGeneralParamQueryFrame(sensorID, 0x0800)
-	if err != nil {
-		return fmt.Errorf("构造拓扑查询失败: %w", err)
-	}
-	eidStr, _ = eidValue.(string)
-	//发送命令
-	relay.SendFrame(eidStr, frame)
-	d.lc.Infof("已发送拓扑查询命令到设备 %s (EID: %s)", deviceName, eidStr)
-	return nil
+	return eidStr, sensorID, nil
 }
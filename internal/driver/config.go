@@ -0,0 +1,472 @@
+package driver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	bootstrapMessaging "github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/messaging"
+	"github.com/linjuya-lu/device-wiresink-go/internal/airtime"
+	"github.com/linjuya-lu/device-wiresink-go/internal/frameparser"
+	"github.com/linjuya-lu/device-wiresink-go/internal/history"
+	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
+	"github.com/linjuya-lu/device-wiresink-go/internal/sfqueue"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
+)
+
+// configuration.yaml 中的自定义配置段
+const (
+	customConfigSection   = "WireSink"
+	writableConfigSection = customConfigSection + "/Writable"
+)
+
+// 服务自定义配置：WireSink 段。MessageBus 沿用 SDK 同源的公共配置，不在此重复
+type ServiceConfig struct {
+	WireSink WireSinkInfo
+}
+
+// 汇聚网关相关配置
+type WireSinkInfo struct {
+	// 传输方式: messagebus / mqtt / mqtt5 / serial / tcp / udp / loopback
+	Transport string
+	// 汇聚模块 EID，下行 SinkPayload.Eid 统一填写该值
+	SinkEid string
+	// 汇聚节点对应的 EdgeX 设备，连接状态等写到该设备上
+	SinkDeviceName string
+	// 设备与 Profile 文件，相对服务工作目录
+	DevicesFile string
+	ProfilesDir string
+	// 上报未覆盖参量时自动生成设备 Profile，生成后不会指定给设备，由运维人员审核后改用
+	AutoGenerateProfile bool
+
+	// mqtt / mqtt5 模式直连的网关 broker，ssl:// 或 tls:// 开头时启用 TLS
+	BrokerURL string
+	// broker 认证方式: none / usernamepassword / clientcert / cacert
+	// 凭据从 SecretStore 中 BrokerSecretName 读取（username、password、clientcert、clientkey、cacert）
+	BrokerAuthMode       string
+	BrokerSecretName     string
+	BrokerSkipCertVerify bool
+	// 断线重连退避：从 Min 开始翻倍，最大 Max
+	BrokerReconnectMin string
+	BrokerReconnectMax string
+	// 断线期间下行缓存条数上限及落盘文件
+	BrokerOutboxSize int
+	BrokerOutboxFile string
+	// mqtt5 模式等待网关返回投递结果的时间：命令下行同步等待，其余下行超时后标记为失败
+	BrokerResponseTimeout string
+	// serial 模式
+	SerialPort     string
+	SerialBaudRate int
+	// tcp/udp 模式
+	SocketListen  string
+	SocketFraming string
+	// 运行时状态快照文件，为空时不落盘；每 StateSaveInterval 保存一次，
+	// 启动时恢复，超过 StateRetention 的值丢弃
+	StateFile         string
+	StateSaveInterval string
+	StateRetention    string
+	// 上报缓冲文件及条数上限，SDK 上报阻塞时读数在此排队，为空时直接交给 SDK；
+	// 交给 SDK 后发布失败的事件不在此补报
+	ReadingBufferFile string
+	ReadingBufferSize int
+
+	Writable WireSinkWritable
+}
+
+// 运行时可修改的配置，经配置中心修改后立即生效
+type WireSinkWritable struct {
+	// 上下行 topic，messagebus 模式前面拼上公共配置的 MessageBus.BaseTopicPrefix（mqtt 模式固定为 edgex）
+	UpTopic   string
+	DownTopic string
+	// 分片重组超时
+	ReassemblyTimeout string
+	// 控制命令等待传感器响应的时间
+	CommandTimeout string
+	// 每个设备资源保留的历史值条数，0 表示不记录历史
+	HistorySize int
+	// 读数超出合理范围或 Profile minimum/maximum 时的处理: keep / drop / clamp，资源属性 outOfRange 优先
+	OutOfRange string
+	// 休眠传感器的下行暂存
+	StoreForward StoreForwardInfo
+	// 汇聚节点下行空口预算，按传感器上报所经的汇聚节点分别调度
+	Airtime AirtimeInfo
+}
+
+// 汇聚节点无线发送能力有限，下行按优先级排队并限制速率和占空比
+type AirtimeInfo struct {
+	// 每秒最多下发帧数及突发上限
+	Rate  float64
+	Burst int
+	// 占空比 (0,1]，在 DutyWindow 内统计
+	DutyCycle  float64
+	DutyWindow string
+	// 估算单帧空口时长：FrameOverhead + 帧长 / BitRate(bps)
+	BitRate       int
+	FrameOverhead string
+	// 批量任务相邻两帧的最小间隔
+	BulkInterval string
+	// 非批量下行最长排队时间，超过返回失败
+	MaxDelay string
+	// 排队上限
+	QueueSize int
+}
+
+// 休眠传感器只在上报后短暂接收，下行暂存到其下一次上行后再发
+type StoreForwardInfo struct {
+	// 休眠传感器 EID
+	Eids []string
+	// 收到上行后可直接下发的时间窗口
+	ReceiveWindow string
+	// 暂存下行的默认有效期
+	TTL string
+	// 每个传感器最多暂存条数
+	MaxPerSensor int
+}
+
+func (c *ServiceConfig) UpdateFromRaw(rawConfig interface{}) bool {
+	cfg, ok := rawConfig.(*ServiceConfig)
+	if !ok {
+		return false
+	}
+	*c = *cfg
+	return true
+}
+
+// 未配置的项使用默认值，与修改前的硬编码保持一致
+func (c *ServiceConfig) setDefaults() {
+	w := &c.WireSink
+	if w.Transport == "" {
+		w.Transport = transport.NameMessageBus
+	}
+	if w.SinkEid == "" {
+		w.SinkEid = "238A0841D828"
+	}
+	if w.SinkDeviceName == "" {
+		w.SinkDeviceName = "Sink-Node"
+	}
+	if w.DevicesFile == "" {
+		w.DevicesFile = "./res/devices/devices.yaml"
+	}
+	if w.ProfilesDir == "" {
+		w.ProfilesDir = "./res/profiles"
+	}
+	if w.BrokerURL == "" {
+		w.BrokerURL = "tcp://172.16.19.101:1883"
+	}
+	if w.BrokerAuthMode == "" {
+		w.BrokerAuthMode = bootstrapMessaging.AuthModeNone
+	}
+	if w.BrokerSecretName == "" {
+		w.BrokerSecretName = "wiresink-broker"
+	}
+	if w.BrokerReconnectMin == "" {
+		w.BrokerReconnectMin = "1s"
+	}
+	if w.BrokerReconnectMax == "" {
+		w.BrokerReconnectMax = "60s"
+	}
+	if w.BrokerOutboxSize == 0 {
+		w.BrokerOutboxSize = 256
+	}
+	if w.BrokerOutboxFile == "" {
+		w.BrokerOutboxFile = "./res/data/downlink-outbox.json"
+	}
+	if w.BrokerResponseTimeout == "" {
+		w.BrokerResponseTimeout = "5s"
+	}
+	if w.SerialPort == "" {
+		w.SerialPort = "/dev/ttyUSB0"
+	}
+	if w.SerialBaudRate == 0 {
+		w.SerialBaudRate = 115200
+	}
+	if w.SocketListen == "" {
+		w.SocketListen = ":59911"
+	}
+	if w.SocketFraming == "" {
+		w.SocketFraming = transport.FramingLine
+	}
+	if w.StateSaveInterval == "" {
+		w.StateSaveInterval = "1m"
+	}
+	if w.StateRetention == "" {
+		w.StateRetention = "24h"
+	}
+	if w.ReadingBufferSize == 0 {
+		w.ReadingBufferSize = 100000
+	}
+	w.Writable.setDefaults()
+}
+
+func (w *WireSinkWritable) setDefaults() {
+	if w.UpTopic == "" {
+		w.UpTopic = "service/request/device_wiresink/up"
+	}
+	if w.DownTopic == "" {
+		w.DownTopic = "server/response/device_wiresink/down"
+	}
+	if w.ReassemblyTimeout == "" {
+		w.ReassemblyTimeout = "20s"
+	}
+	if w.CommandTimeout == "" {
+		w.CommandTimeout = "10s"
+	}
+	if w.OutOfRange == "" {
+		w.OutOfRange = outOfRangeKeep
+	}
+	sf := &w.StoreForward
+	if sf.ReceiveWindow == "" {
+		sf.ReceiveWindow = "2s"
+	}
+	if sf.TTL == "" {
+		sf.TTL = "24h"
+	}
+	if sf.MaxPerSensor == 0 {
+		sf.MaxPerSensor = 32
+	}
+	a := &w.Airtime
+	if a.Rate == 0 {
+		a.Rate = 10
+	}
+	if a.Burst == 0 {
+		a.Burst = 10
+	}
+	if a.DutyCycle == 0 {
+		a.DutyCycle = 0.1
+	}
+	if a.DutyWindow == "" {
+		a.DutyWindow = "1m"
+	}
+	if a.BitRate == 0 {
+		a.BitRate = 9600
+	}
+	if a.FrameOverhead == "" {
+		a.FrameOverhead = "20ms"
+	}
+	if a.BulkInterval == "" {
+		a.BulkInterval = "500ms"
+	}
+	if a.MaxDelay == "" {
+		a.MaxDelay = "30s"
+	}
+	if a.QueueSize == 0 {
+		a.QueueSize = 1024
+	}
+}
+
+func (c *ServiceConfig) Validate() error {
+	w := c.WireSink
+	switch strings.ToLower(w.Transport) {
+	case transport.NameMessageBus, transport.NameMQTT, transport.NameMQTT5, transport.NameSerial,
+		transport.NameTCP, transport.NameUDP, transport.NameLoopback:
+	default:
+		return fmt.Errorf("WireSink.Transport 不支持 %q", w.Transport)
+	}
+	switch w.BrokerAuthMode {
+	case bootstrapMessaging.AuthModeNone, bootstrapMessaging.AuthModeUsernamePassword,
+		bootstrapMessaging.AuthModeCert, bootstrapMessaging.AuthModeCA:
+	default:
+		return fmt.Errorf("WireSink.BrokerAuthMode 不支持 %q", w.BrokerAuthMode)
+	}
+	if _, err := parsePositiveDuration(w.BrokerReconnectMin); err != nil {
+		return fmt.Errorf("WireSink.BrokerReconnectMin %w", err)
+	}
+	if _, err := parsePositiveDuration(w.BrokerReconnectMax); err != nil {
+		return fmt.Errorf("WireSink.BrokerReconnectMax %w", err)
+	}
+	if w.BrokerOutboxSize < 0 {
+		return fmt.Errorf("WireSink.BrokerOutboxSize 非法: %d", w.BrokerOutboxSize)
+	}
+	if _, err := parsePositiveDuration(w.BrokerResponseTimeout); err != nil {
+		return fmt.Errorf("WireSink.BrokerResponseTimeout %w", err)
+	}
+	if w.SerialBaudRate <= 0 {
+		return fmt.Errorf("WireSink.SerialBaudRate 非法: %d", w.SerialBaudRate)
+	}
+	if _, err := parsePositiveDuration(w.StateSaveInterval); err != nil {
+		return fmt.Errorf("WireSink.StateSaveInterval %w", err)
+	}
+	if _, err := parsePositiveDuration(w.StateRetention); err != nil {
+		return fmt.Errorf("WireSink.StateRetention %w", err)
+	}
+	if w.ReadingBufferSize < 0 {
+		return fmt.Errorf("WireSink.ReadingBufferSize 非法: %d", w.ReadingBufferSize)
+	}
+	return w.Writable.Validate()
+}
+
+func (w WireSinkWritable) Validate() error {
+	if w.UpTopic == "" || w.DownTopic == "" {
+		return fmt.Errorf("WireSink.Writable 上下行 topic 不能为空")
+	}
+	if _, err := parsePositiveDuration(w.ReassemblyTimeout); err != nil {
+		return fmt.Errorf("WireSink.Writable.ReassemblyTimeout %w", err)
+	}
+	if _, err := parsePositiveDuration(w.CommandTimeout); err != nil {
+		return fmt.Errorf("WireSink.Writable.CommandTimeout %w", err)
+	}
+	if w.HistorySize < 0 {
+		return fmt.Errorf("WireSink.Writable.HistorySize 非法: %d", w.HistorySize)
+	}
+	if !validOutOfRange(w.OutOfRange) {
+		return fmt.Errorf("WireSink.Writable.OutOfRange 非法: %q", w.OutOfRange)
+	}
+	sf := w.StoreForward
+	if _, err := parsePositiveDuration(sf.ReceiveWindow); err != nil {
+		return fmt.Errorf("WireSink.Writable.StoreForward.ReceiveWindow %w", err)
+	}
+	if _, err := parsePositiveDuration(sf.TTL); err != nil {
+		return fmt.Errorf("WireSink.Writable.StoreForward.TTL %w", err)
+	}
+	if sf.MaxPerSensor < 0 {
+		return fmt.Errorf("WireSink.Writable.StoreForward.MaxPerSensor 非法: %d", sf.MaxPerSensor)
+	}
+	for _, eid := range sf.Eids {
+		if _, err := hex.DecodeString(eid); err != nil || len(eid) != 12 {
+			return fmt.Errorf("WireSink.Writable.StoreForward.Eids 非法: %q", eid)
+		}
+	}
+	return w.Airtime.Validate()
+}
+
+func (a AirtimeInfo) Validate() error {
+	if a.Rate <= 0 || a.Burst <= 0 {
+		return fmt.Errorf("WireSink.Writable.Airtime.Rate/Burst 必须大于 0: %v/%d", a.Rate, a.Burst)
+	}
+	if a.DutyCycle <= 0 || a.DutyCycle > 1 {
+		return fmt.Errorf("WireSink.Writable.Airtime.DutyCycle 应在 (0,1]: %v", a.DutyCycle)
+	}
+	if _, err := parsePositiveDuration(a.DutyWindow); err != nil {
+		return fmt.Errorf("WireSink.Writable.Airtime.DutyWindow %w", err)
+	}
+	if a.BitRate <= 0 {
+		return fmt.Errorf("WireSink.Writable.Airtime.BitRate 非法: %d", a.BitRate)
+	}
+	for name, v := range map[string]string{"FrameOverhead": a.FrameOverhead, "BulkInterval": a.BulkInterval} {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("WireSink.Writable.Airtime.%s 非法: %q", name, v)
+		}
+	}
+	if _, err := parsePositiveDuration(a.MaxDelay); err != nil {
+		return fmt.Errorf("WireSink.Writable.Airtime.MaxDelay %w", err)
+	}
+	if a.QueueSize < 0 {
+		return fmt.Errorf("WireSink.Writable.Airtime.QueueSize 非法: %d", a.QueueSize)
+	}
+	return nil
+}
+
+// 转为调度参数，已校验过格式
+func (a AirtimeInfo) config() airtime.Config {
+	window, _ := time.ParseDuration(a.DutyWindow)
+	overhead, _ := time.ParseDuration(a.FrameOverhead)
+	bulk, _ := time.ParseDuration(a.BulkInterval)
+	maxDelay, _ := time.ParseDuration(a.MaxDelay)
+	return airtime.Config{
+		Rate:          a.Rate,
+		Burst:         a.Burst,
+		DutyCycle:     a.DutyCycle,
+		DutyWindow:    window,
+		BitRate:       a.BitRate,
+		FrameOverhead: overhead,
+		BulkInterval:  bulk,
+		MaxDelay:      maxDelay,
+		QueueSize:     a.QueueSize,
+	}
+}
+
+// 解析 "20s" 形式的时长，要求大于 0
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("格式非法 %q: %w", s, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("必须大于 0: %q", s)
+	}
+	return d, nil
+}
+
+// 当前配置的副本
+func (d *WireSinkDriver) config() ServiceConfig {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return *d.serviceConfig
+}
+
+// 经 SDK 加载自定义配置并监听 Writable 变化
+func (d *WireSinkDriver) loadServiceConfig() error {
+	cfg := &ServiceConfig{}
+	if err := d.sdk.LoadCustomConfig(cfg, customConfigSection); err != nil {
+		return fmt.Errorf("加载自定义配置 %s 失败: %w", customConfigSection, err)
+	}
+	cfg.setDefaults()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("自定义配置校验失败: %w", err)
+	}
+	d.configMu.Lock()
+	d.serviceConfig = cfg
+	d.configMu.Unlock()
+	d.applyWritable(cfg.WireSink.Writable)
+	d.lc.Infof("自定义配置已加载: 传输=%s, 设备文件=%s", cfg.WireSink.Transport, cfg.WireSink.DevicesFile)
+
+	watched := cfg.WireSink.Writable
+	if err := d.sdk.ListenForCustomConfigChanges(&watched, writableConfigSection, d.updateWritableConfig); err != nil {
+		return fmt.Errorf("监听配置 %s 失败: %w", writableConfigSection, err)
+	}
+	return nil
+}
+
+// 配置中心修改 Writable 后回调
+func (d *WireSinkDriver) updateWritableConfig(rawWritableConfig interface{}) {
+	updated, ok := rawWritableConfig.(*WireSinkWritable)
+	if !ok {
+		d.lc.Errorf("Writable 配置类型错误: %T", rawWritableConfig)
+		return
+	}
+	w := *updated
+	w.setDefaults()
+	if err := w.Validate(); err != nil {
+		d.lc.Errorf("忽略非法的 Writable 配置: %v", err)
+		return
+	}
+
+	d.configMu.Lock()
+	old := d.serviceConfig.WireSink.Writable
+	d.serviceConfig.WireSink.Writable = w
+	d.configMu.Unlock()
+
+	d.applyWritable(w)
+	if !usesTopics(d.config().WireSink.Transport) {
+		return
+	}
+	if old.UpTopic != w.UpTopic || old.DownTopic != w.DownTopic {
+		d.lc.Infof("上下行 topic 已修改为 %s / %s，重建传输", w.UpTopic, w.DownTopic)
+		if err := d.restartTransport(); err != nil {
+			d.lc.Errorf("重建传输失败: %v", err)
+		}
+	}
+}
+
+// 把超时、周期类配置下发到各模块，已校验过格式
+func (d *WireSinkDriver) applyWritable(w WireSinkWritable) {
+	timeout, _ := parsePositiveDuration(w.ReassemblyTimeout)
+	frameparser.SetReassembleTimeout(timeout)
+	window, _ := parsePositiveDuration(w.StoreForward.ReceiveWindow)
+	ttl, _ := parsePositiveDuration(w.StoreForward.TTL)
+	sfqueue.Configure(sfqueue.Config{
+		Eids:         w.StoreForward.Eids,
+		Window:       window,
+		TTL:          ttl,
+		MaxPerSensor: w.StoreForward.MaxPerSensor,
+	})
+	history.Configure(w.HistorySize)
+	relay.ConfigureAirtime(d.config().WireSink.SinkEid, w.Airtime.config())
+}
+
+// 仅 messagebus / mqtt 传输使用 topic
+func usesTopics(name string) bool {
+	name = strings.ToLower(name)
+	return name == transport.NameMessageBus || name == transport.NameMQTT || name == transport.NameMQTT5
+}
@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// 资源属性：上报过滤。设置任一项后，值与上次上报相比没有明显变化时不上报
+const (
+	// 绝对死区，变化量超过该值才上报
+	deadbandAttribute = "deadband"
+	// 百分比死区，变化量超过上次上报值的该百分比才上报
+	deadbandPercentAttribute = "deadbandPercent"
+	// 最小上报间隔，如 "10s"，间隔内的变化不上报
+	minIntervalAttribute = "minInterval"
+	// 心跳间隔，如 "10m"，距上次上报超过该时长时即使未变化也上报
+	heartbeatAttribute = "heartbeat"
+)
+
+// 资源的上报过滤参数
+type reportFilter struct {
+	deadband    float64
+	percent     float64
+	minInterval time.Duration
+	heartbeat   time.Duration
+}
+
+// 上次上报的值
+type reportedValue struct {
+	value interface{}
+	at    time.Time
+}
+
+// 资源属性中的过滤参数，均未设置时返回 false
+func (d *WireSinkDriver) reportFilterOf(deviceName, resourceName string) (reportFilter, bool, error) {
+	var f reportFilter
+	dr, ok := d.sdk.DeviceResource(deviceName, resourceName)
+	if !ok || len(dr.Attributes) == 0 {
+		return f, false, nil
+	}
+	set := false
+	for name, dst := range map[string]*float64{deadbandAttribute: &f.deadband, deadbandPercentAttribute: &f.percent} {
+		raw, ok := dr.Attributes[name]
+		if !ok {
+			continue
+		}
+		v, ok := toFloat64(raw)
+		if !ok || v < 0 {
+			return f, false, fmt.Errorf("资源 %s 的 %s 属性非法: %v", resourceName, name, raw)
+		}
+		*dst, set = v, true
+	}
+	for name, dst := range map[string]*time.Duration{minIntervalAttribute: &f.minInterval, heartbeatAttribute: &f.heartbeat} {
+		raw, ok := dr.Attributes[name]
+		if !ok {
+			continue
+		}
+		s, _ := raw.(string)
+		v, err := time.ParseDuration(s)
+		if err != nil || v < 0 {
+			return f, false, fmt.Errorf("资源 %s 的 %s 属性非法: %v", resourceName, name, raw)
+		}
+		*dst, set = v, true
+	}
+	return f, set, nil
+}
+
+// 按资源属性过滤本次上报，返回需要上报的值；未设置过滤属性的资源照常上报
+func (d *WireSinkDriver) filterReport(deviceName string, values map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	out := make(map[string]interface{}, len(values))
+	d.reportMu.Lock()
+	defer d.reportMu.Unlock()
+	last := d.lastReported[deviceName]
+	if last == nil {
+		last = make(map[string]reportedValue)
+		d.lastReported[deviceName] = last
+	}
+	for name, val := range values {
+		f, ok, err := d.reportFilterOf(deviceName, name)
+		if err != nil {
+			d.lc.Warnf("%v，不过滤", err)
+		}
+		if !ok {
+			out[name] = val
+			continue
+		}
+		prev, seen := last[name]
+		if seen && !f.shouldReport(prev, val, now) {
+			d.lc.Debugf("设备 %s 资源 %s 未明显变化，不上报: %v", deviceName, name, val)
+			continue
+		}
+		last[name] = reportedValue{value: val, at: now}
+		out[name] = val
+	}
+	return out
+}
+
+// 心跳到期必报；最小间隔内不报；其余按死区判断是否有明显变化
+func (f reportFilter) shouldReport(prev reportedValue, val interface{}, now time.Time) bool {
+	elapsed := now.Sub(prev.at)
+	if f.heartbeat > 0 && elapsed >= f.heartbeat {
+		return true
+	}
+	if elapsed < f.minInterval {
+		return false
+	}
+	cur, ok1 := toFloat64(val)
+	old, ok2 := toFloat64(prev.value)
+	if !ok1 || !ok2 {
+		return !reflect.DeepEqual(val, prev.value)
+	}
+	delta := math.Abs(cur - old)
+	if f.deadband == 0 && f.percent == 0 {
+		return delta > 0
+	}
+	if f.deadband > 0 && delta > f.deadband {
+		return true
+	}
+	return f.percent > 0 && delta > math.Abs(old)*f.percent/100
+}
+
+// 设备删除时清除上报记录
+func (d *WireSinkDriver) forgetReported(deviceName string) {
+	d.reportMu.Lock()
+	defer d.reportMu.Unlock()
+	delete(d.lastReported, deviceName)
+}
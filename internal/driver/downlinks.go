@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v4/dtos/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+)
+
+// 下行跟踪查询接口
+const (
+	apiDownlinkRoute   = common.ApiBase + "/downlink"
+	apiDownlinkIdRoute = apiDownlinkRoute + "/" + common.Id + "/:" + common.Id
+)
+
+// 默认返回条数
+const defaultDownlinkLimit = 50
+
+type downlinksResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Summary                map[string]int    `json:"summary"`
+	Downlinks              []downlink.Record `json:"downlinks"`
+}
+
+type downlinkResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Downlink               downlink.Record `json:"downlink"`
+}
+
+func (d *WireSinkDriver) addDownlinkRoutes() error {
+	if err := d.sdk.AddCustomRoute(apiDownlinkRoute, interfaces.Authenticated, d.queryDownlinks, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiDownlinkRoute, err)
+	}
+	if err := d.sdk.AddCustomRoute(apiDownlinkIdRoute, interfaces.Authenticated, d.getDownlink, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiDownlinkIdRoute, err)
+	}
+	return nil
+}
+
+// GET /api/v3/downlink?eid=&status=&limit=  最近的下行及各状态条数
+func (d *WireSinkDriver) queryDownlinks(c echo.Context) error {
+	f := downlink.Filter{
+		Eid:    c.QueryParam("eid"),
+		Status: c.QueryParam("status"),
+		Limit:  defaultDownlinkLimit,
+	}
+	if f.Status != "" && !slices.Contains(downlink.Statuses(), f.Status) {
+		return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", fmt.Sprintf("status 取值应为 %v", downlink.Statuses()), http.StatusBadRequest))
+	}
+	if s := c.QueryParam(common.Limit); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", "limit 非法: "+s, http.StatusBadRequest))
+		}
+		f.Limit = n
+	}
+	return c.JSON(http.StatusOK, downlinksResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Summary:      downlink.Summary(),
+		Downlinks:    downlink.Recent(f),
+	})
+}
+
+// GET /api/v3/downlink/id/:id
+func (d *WireSinkDriver) getDownlink(c echo.Context) error {
+	id := c.Param(common.Id)
+	r, ok := downlink.Get(id)
+	if !ok {
+		return c.JSON(http.StatusNotFound, dtoCommon.NewBaseResponse("", "未找到下行 "+id, http.StatusNotFound))
+	}
+	return c.JSON(http.StatusOK, downlinkResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Downlink:     r,
+	})
+}
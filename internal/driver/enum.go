@@ -0,0 +1,184 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+)
+
+// 资源属性：由状态参量派生的可读资源。派生资源设置 source 指向原始参量，再选一种解码方式：
+//   - enum: {"0": "分", "1": "合"}，输出对应标签（String），未定义的值输出 unknown(n)
+//   - bit: 3，输出该位是否置位（Bool）
+//   - bits: {"0": "ADC", "1": "EEPROM"}，输出置位各位的标签，以逗号分隔（String），均未置位时输出 none 属性的值
+const (
+	sourceAttribute = "source"
+	enumAttribute   = "enum"
+	bitAttribute    = "bit"
+	bitsAttribute   = "bits"
+	noneAttribute   = "none"
+)
+
+// 由原始参量值计算派生资源的值，dr 不是派生资源或原始值不是整数时返回 false
+func deriveValue(dr models.DeviceResource, raw interface{}) (interface{}, bool, error) {
+	n, ok := toUint64(raw)
+	if !ok {
+		return nil, false, nil
+	}
+	if enum, ok := dr.Attributes[enumAttribute]; ok {
+		labels, err := labelMap(enum)
+		if err != nil {
+			return nil, false, fmt.Errorf("资源 %s 的 %s 属性非法: %w", dr.Name, enumAttribute, err)
+		}
+		if label, ok := labels[n]; ok {
+			return label, true, nil
+		}
+		return fmt.Sprintf("unknown(%d)", n), true, nil
+	}
+	if b, ok := dr.Attributes[bitAttribute]; ok {
+		bit, ok := toUint64(b)
+		if !ok || bit > 63 {
+			return nil, false, fmt.Errorf("资源 %s 的 %s 属性非法: %v", dr.Name, bitAttribute, b)
+		}
+		return n&(1<<bit) != 0, true, nil
+	}
+	if bits, ok := dr.Attributes[bitsAttribute]; ok {
+		labels, err := labelMap(bits)
+		if err != nil {
+			return nil, false, fmt.Errorf("资源 %s 的 %s 属性非法: %w", dr.Name, bitsAttribute, err)
+		}
+		idx := make([]uint64, 0, len(labels))
+		for i := range labels {
+			if i <= 63 && n&(1<<i) != 0 {
+				idx = append(idx, i)
+			}
+		}
+		if len(idx) == 0 {
+			none, _ := dr.Attributes[noneAttribute].(string)
+			return none, true, nil
+		}
+		sort.Slice(idx, func(a, b int) bool { return idx[a] < idx[b] })
+		set := make([]string, len(idx))
+		for k, i := range idx {
+			set[k] = labels[i]
+		}
+		return strings.Join(set, ", "), true, nil
+	}
+	return nil, false, fmt.Errorf("资源 %s 设置了 %s 但缺少 %s/%s/%s 属性", dr.Name, sourceAttribute, enumAttribute, bitAttribute, bitsAttribute)
+}
+
+// {"0": "分", "1": "合"} 形式的属性，键为十进制或 0x 开头的十六进制整数
+func labelMap(v interface{}) (map[uint64]string, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("应为键值对，实际 %T", v)
+	}
+	out := make(map[uint64]string, len(m))
+	for k, label := range m {
+		n, err := strconv.ParseUint(k, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("键 %q 不是整数", k)
+		}
+		out[n] = fmt.Sprint(label)
+	}
+	return out, nil
+}
+
+// 设备 Profile 中的派生资源，按原始参量分组
+func (d *WireSinkDriver) derivedResources(deviceName string) map[string][]models.DeviceResource {
+	dev, err := d.sdk.GetDeviceByName(deviceName)
+	if err != nil {
+		return nil
+	}
+	profile, err := d.sdk.GetProfileByName(dev.ProfileName)
+	if err != nil {
+		return nil
+	}
+	var out map[string][]models.DeviceResource
+	for _, dr := range profile.DeviceResources {
+		src, ok := dr.Attributes[sourceAttribute].(string)
+		if !ok || src == "" {
+			continue
+		}
+		if out == nil {
+			out = make(map[string][]models.DeviceResource)
+		}
+		out[src] = append(out[src], dr)
+	}
+	return out
+}
+
+// 为本次上报中的状态参量补充派生资源的值
+func (d *WireSinkDriver) addDerivedValues(deviceName string, values map[string]interface{}) {
+	derived := d.derivedResources(deviceName)
+	if len(derived) == 0 {
+		return
+	}
+	for src, raw := range values {
+		for _, dr := range derived[src] {
+			v, ok, err := deriveValue(dr, raw)
+			if err != nil {
+				d.lc.Warnf("设备 %s: %v", deviceName, err)
+				continue
+			}
+			if ok {
+				values[dr.Name] = v
+			}
+		}
+	}
+}
+
+// 读取派生资源：由设备存储中原始参量的当前值计算，不是派生资源时返回 false
+func (d *WireSinkDriver) readDerived(deviceName, resourceName string, values map[string]interface{}) (interface{}, bool) {
+	dr, ok := d.sdk.DeviceResource(deviceName, resourceName)
+	if !ok {
+		return nil, false
+	}
+	src, ok := dr.Attributes[sourceAttribute].(string)
+	if !ok || src == "" {
+		return nil, false
+	}
+	raw, ok := values[src]
+	if !ok {
+		return nil, false
+	}
+	v, ok, err := deriveValue(dr, raw)
+	if err != nil {
+		d.lc.Warnf("设备 %s: %v", deviceName, err)
+		return nil, false
+	}
+	return v, ok
+}
+
+// 核对 Profile 中派生资源的定义：原始参量须存在，解码属性合法，ValueType 与解码方式一致
+func (d *WireSinkDriver) checkDerivedResources(profile models.DeviceProfile) {
+	names := make(map[string]bool, len(profile.DeviceResources))
+	for _, dr := range profile.DeviceResources {
+		names[dr.Name] = true
+	}
+	for _, dr := range profile.DeviceResources {
+		src, ok := dr.Attributes[sourceAttribute].(string)
+		if !ok || src == "" {
+			continue
+		}
+		if _, known := config.ParamByName(src); !known && !names[src] {
+			d.lc.Warnf("Profile %s 资源 %s 的 %s %q 不是已知参量", profile.Name, dr.Name, sourceAttribute, src)
+		}
+		v, _, err := deriveValue(dr, uint64(0))
+		if err != nil {
+			d.lc.Warnf("Profile %s: %v", profile.Name, err)
+			continue
+		}
+		want := common.ValueTypeString
+		if _, ok := v.(bool); ok {
+			want = common.ValueTypeBool
+		}
+		if dr.Properties.ValueType != want {
+			d.lc.Warnf("Profile %s 资源 %s 的 ValueType 应为 %s，实际 %s", profile.Name, dr.Name, want, dr.Properties.ValueType)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package driver
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+)
+
+const (
+	// 资源属性或请求参数：缓存值超过该时长时先向传感器查询，如 "30s"
+	maxAgeAttribute = "maxAge"
+	// 资源属性：刷新该资源时执行的查询命令，缺省为监测数据查询
+	refreshAttribute      = "refresh"
+	defaultRefreshCommand = "Monitoring_Data_Query"
+	// SDK 把 GET 请求的查询参数放在该属性中
+	urlRawQueryAttribute = "urlRawQuery"
+)
+
+// 读数 Tags：传感器未在超时内响应、返回的是过期缓存值时标记
+const (
+	tagStale = "stale"
+	tagAge   = "age"
+)
+
+// 资源的 maxAge：请求参数优先于资源属性，均未设置时返回 false
+func maxAgeOf(req dsModels.CommandRequest) (time.Duration, bool, error) {
+	s := ""
+	if raw, ok := req.Attributes[urlRawQueryAttribute].(string); ok && raw != "" {
+		q, err := url.ParseQuery(raw)
+		if err != nil {
+			return 0, false, fmt.Errorf("查询参数非法 %q: %w", raw, err)
+		}
+		s = q.Get(maxAgeAttribute)
+	}
+	if s == "" {
+		s, _ = req.Attributes[maxAgeAttribute].(string)
+	}
+	if s == "" {
+		return 0, false, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, false, fmt.Errorf("%s 非法 %q", maxAgeAttribute, s)
+	}
+	return d, true, nil
+}
+
+// 对超过 maxAge 的资源向传感器发起查询，同一查询命令只执行一次
+// 返回查询后仍未更新的资源及其缓存值年龄（从未收到数据时为 -1）
+func (d *WireSinkDriver) refreshStale(deviceName string, reqs []dsModels.CommandRequest) (map[string]time.Duration, error) {
+	now := time.Now()
+	stale := make(map[string]time.Duration)
+	refresh := make(map[string][]string) // 查询命令 → 资源
+	for _, req := range reqs {
+		maxAge, ok, err := maxAgeOf(req)
+		if err != nil {
+			return nil, fmt.Errorf("资源 %s: %w", req.DeviceResourceName, err)
+		}
+		if !ok {
+			continue
+		}
+		if t, ok := config.Devices().UpdatedAt(deviceName, req.DeviceResourceName); ok && now.Sub(t) <= maxAge {
+			continue
+		}
+		cmd, _ := req.Attributes[refreshAttribute].(string)
+		if cmd == "" {
+			cmd = defaultRefreshCommand
+		}
+		refresh[cmd] = append(refresh[cmd], req.DeviceResourceName)
+	}
+
+	for cmd, resources := range refresh {
+		c, ok := d.controlCommands()[cmd]
+		if !ok {
+			return nil, fmt.Errorf("资源 %v 的 %s 属性 %q 不是支持的命令", resources, refreshAttribute, cmd)
+		}
+		d.lc.Infof("设备 %s 资源 %v 缓存过期，执行 %s", deviceName, resources, cmd)
+		if _, err := c.call(deviceName, c.priority); err != nil {
+			// 传感器可能以普通数据帧上报，仍以资源更新时间为准
+			d.lc.Warnf("设备 %s 刷新 %v 未获得响应: %v", deviceName, resources, err)
+		}
+		for _, res := range resources {
+			t, ok := config.Devices().UpdatedAt(deviceName, res)
+			switch {
+			case !ok:
+				stale[res] = -1
+			case !t.After(now):
+				stale[res] = time.Since(t)
+			}
+		}
+	}
+	return stale, nil
+}
+
+// 标记读数为过期缓存值
+func markStale(cv *dsModels.CommandValue, age time.Duration) {
+	if cv.Tags == nil {
+		cv.Tags = make(map[string]string)
+	}
+	cv.Tags[tagStale] = "true"
+	cv.Tags[tagQuality] = qualityStale
+	if age >= 0 {
+		cv.Tags[tagAge] = age.Round(time.Second).String()
+	}
+}
@@ -0,0 +1,275 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v4/dtos/common"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
+)
+
+// 组命令接口：对汇聚节点下全部传感器、某标签或某 Profile 的设备执行同一控制命令
+const (
+	apiGroupRoute   = common.ApiBase + "/group"
+	apiGroupIdRoute = apiGroupRoute + "/" + common.Id + "/:" + common.Id
+)
+
+const (
+	// 保留最近的组命令条数
+	groupHistory = 64
+	// 同时等待响应的设备数，下行仍经调度器按批量优先级分散
+	groupParallel = 16
+)
+
+// 单台设备的执行状态
+const (
+	groupPending = "pending"
+	groupOK      = "ok"
+	groupHeld    = "held"   // 传感器休眠，命令已暂存
+	groupQueued  = "queued" // 传输断线，命令在断线缓存中待补发
+	groupFailed  = "failed"
+)
+
+// 组命令目标，各条件同时生效；均为空时为全部传感器
+type groupTarget struct {
+	Sink    string   `json:"sink,omitempty"` // 汇聚节点 EID 或设备名，按传感器最近一次上行所经的节点筛选
+	Label   string   `json:"label,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+	Devices []string `json:"devices,omitempty"`
+}
+
+type groupRequest struct {
+	// 控制命令，同写资源名，如 Time_Parameter_Set
+	Command string      `json:"command"`
+	Target  groupTarget `json:"target"`
+}
+
+type groupResult struct {
+	Device string                 `json:"device"`
+	Eid    string                 `json:"eid"`
+	Status string                 `json:"status"`
+	Values map[string]interface{} `json:"values,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+type groupJob struct {
+	ID       string         `json:"id"`
+	Command  string         `json:"command"`
+	Target   groupTarget    `json:"target"`
+	Created  time.Time      `json:"created"`
+	Finished *time.Time     `json:"finished,omitempty"`
+	Summary  map[string]int `json:"summary"`
+	Results  []groupResult  `json:"results"`
+}
+
+type groupResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Group                  groupJob `json:"group"`
+}
+
+type groupsResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Groups                 []groupJob `json:"groups"`
+}
+
+var (
+	groupMu sync.Mutex
+	groups  []*groupJob // 按创建时间排列
+)
+
+func (d *WireSinkDriver) addGroupRoutes() error {
+	if err := d.sdk.AddCustomRoute(apiGroupRoute, interfaces.Authenticated, d.startGroup, http.MethodPost); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiGroupRoute, err)
+	}
+	if err := d.sdk.AddCustomRoute(apiGroupRoute, interfaces.Authenticated, d.listGroups, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiGroupRoute, err)
+	}
+	if err := d.sdk.AddCustomRoute(apiGroupIdRoute, interfaces.Authenticated, d.getGroup, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiGroupIdRoute, err)
+	}
+	return nil
+}
+
+// POST /api/v3/group  {"command": "...", "target": {"sink"|"label"|"profile"|"devices"}}
+// 后台逐台执行并等待各自响应，结果经 GET /api/v3/group/id/:id 查询
+func (d *WireSinkDriver) startGroup(c echo.Context) error {
+	var req groupRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", "请求体非法: "+err.Error(), http.StatusBadRequest))
+	}
+	cmd, ok := d.controlCommands()[req.Command]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", fmt.Sprintf("不支持的命令 %q", req.Command), http.StatusBadRequest))
+	}
+	devices, err := d.groupDevices(req.Target)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", err.Error(), http.StatusBadRequest))
+	}
+	if len(devices) == 0 {
+		return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", "目标下没有传感器", http.StatusBadRequest))
+	}
+
+	job := &groupJob{
+		ID:      uuid.NewString(),
+		Command: req.Command,
+		Target:  req.Target,
+		Created: time.Now(),
+		Results: make([]groupResult, len(devices)),
+	}
+	for i, name := range devices {
+		eid, _ := config.Devices().Get(name, "eid")
+		job.Results[i] = groupResult{Device: name, Eid: fmt.Sprint(eid), Status: groupPending}
+	}
+	groupMu.Lock()
+	groups = append(groups, job)
+	if len(groups) > groupHistory {
+		groups = groups[len(groups)-groupHistory:]
+	}
+	snapshot := job.snapshot()
+	groupMu.Unlock()
+
+	d.lc.Infof("组命令 %s: %s，共 %d 台设备", job.ID, req.Command, len(devices))
+	go d.runGroup(job, cmd)
+	return c.JSON(http.StatusAccepted, groupResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusAccepted),
+		Group:        snapshot,
+	})
+}
+
+// 扇出执行，下行以批量优先级经调度器分散发送，各设备响应按（EID, CtrlType）关联
+func (d *WireSinkDriver) runGroup(job *groupJob, cmd controlCommand) {
+	sem := make(chan struct{}, groupParallel)
+	var wg sync.WaitGroup
+	for i := range job.Results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, device string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			values, err := cmd.call(device, relay.PriorityBulk)
+			groupMu.Lock()
+			defer groupMu.Unlock()
+			r := &job.Results[i]
+			switch {
+			case err != nil:
+				r.Status, r.Error = groupFailed, err.Error()
+			case values[heldKey] == true:
+				r.Status, r.Values = groupHeld, values
+			case values[queuedKey] == true:
+				r.Status, r.Values = groupQueued, values
+			default:
+				r.Status, r.Values = groupOK, values
+			}
+		}(i, job.Results[i].Device)
+	}
+	wg.Wait()
+	groupMu.Lock()
+	now := time.Now()
+	job.Finished = &now
+	summary := job.snapshot().Summary
+	groupMu.Unlock()
+	d.lc.Infof("组命令 %s 完成: %v", job.ID, summary)
+}
+
+// 按目标筛选有 EID 的传感器设备，不含汇聚节点
+func (d *WireSinkDriver) groupDevices(t groupTarget) ([]string, error) {
+	w := d.config().WireSink
+	sink := t.Sink
+	if sink == w.SinkDeviceName {
+		sink = w.SinkEid
+	}
+	if sink != "" && !relay.KnownSink(sink) {
+		return nil, fmt.Errorf("未知汇聚节点 %q，默认为 %s (%s)", t.Sink, w.SinkEid, w.SinkDeviceName)
+	}
+	for _, name := range t.Devices {
+		if _, ok := config.Devices().Get(name, "eid"); !ok {
+			return nil, fmt.Errorf("设备 %s 没有 EID", name)
+		}
+	}
+	var names []string
+	for _, dev := range d.sdk.Devices() {
+		if dev.Name == w.SinkDeviceName || slices.Contains(names, dev.Name) {
+			continue
+		}
+		if len(t.Devices) > 0 && !slices.Contains(t.Devices, dev.Name) {
+			continue
+		}
+		if t.Label != "" && !slices.Contains(dev.Labels, t.Label) {
+			continue
+		}
+		if t.Profile != "" && dev.ProfileName != t.Profile {
+			continue
+		}
+		eid, ok := config.Devices().Get(dev.Name, "eid")
+		if !ok {
+			continue
+		}
+		if s, _ := eid.(string); sink != "" && !strings.EqualFold(relay.SinkOf(s), sink) {
+			continue
+		}
+		names = append(names, dev.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// 调用方持有 groupMu
+func (j *groupJob) snapshot() groupJob {
+	s := *j
+	s.Results = slices.Clone(j.Results)
+	s.Summary = make(map[string]int)
+	for _, r := range j.Results {
+		s.Summary[r.Status]++
+	}
+	return s
+}
+
+// GET /api/v3/group  最近的组命令，新的在前
+func (d *WireSinkDriver) listGroups(c echo.Context) error {
+	groupMu.Lock()
+	out := make([]groupJob, 0, len(groups))
+	for i := len(groups) - 1; i >= 0; i-- {
+		out = append(out, groups[i].snapshot())
+	}
+	groupMu.Unlock()
+	return c.JSON(http.StatusOK, groupsResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Groups:       out,
+	})
+}
+
+// GET /api/v3/group/id/:id  各传感器的执行结果
+func (d *WireSinkDriver) getGroup(c echo.Context) error {
+	id := c.Param(common.Id)
+	groupMu.Lock()
+	var job *groupJob
+	for _, g := range groups {
+		if g.ID == id {
+			job = g
+			break
+		}
+	}
+	var snapshot groupJob
+	if job != nil {
+		snapshot = job.snapshot()
+	}
+	groupMu.Unlock()
+	if job == nil {
+		return c.JSON(http.StatusNotFound, dtoCommon.NewBaseResponse("", "未找到组命令 "+id, http.StatusNotFound))
+	}
+	return c.JSON(http.StatusOK, groupResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Group:        snapshot,
+	})
+}
@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v4/dtos/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-wiresink-go/internal/history"
+)
+
+// 资源值历史查询接口
+const (
+	apiHistoryRoute     = common.ApiBase + "/history"
+	apiHistoryNameRoute = apiHistoryRoute + "/" + common.Name + "/:" + common.Name
+)
+
+type resourceHistory struct {
+	Resource string           `json:"resource"`
+	Samples  []history.Sample `json:"samples,omitempty"`
+	Buckets  []history.Bucket `json:"buckets,omitempty"`
+}
+
+type historyResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Device                 string            `json:"device"`
+	Step                   string            `json:"step,omitempty"`
+	Resources              []resourceHistory `json:"resources"`
+}
+
+func (d *WireSinkDriver) addHistoryRoutes() error {
+	if err := d.sdk.AddCustomRoute(apiHistoryNameRoute, interfaces.Authenticated, d.queryHistory, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiHistoryNameRoute, err)
+	}
+	return nil
+}
+
+// GET /api/v3/history/name/:name?resource=&start=&end=&since=&step=&limit=
+//   - resource: 资源名，逗号分隔，为空时为全部有历史的资源
+//   - start/end: Unix 纳秒或 RFC3339；since: 最近一段时长，如 1h，与 start 二选一
+//   - step: 降采样间隔，如 1m，按段返回平均、最小、最大值
+//   - limit: 每个资源最多返回的条数（降采样前），取最新的
+//
+// NaN、±Inf 无法以 JSON 表示，value 返回 null，quality 注明原值
+func (d *WireSinkDriver) queryHistory(c echo.Context) error {
+	name := c.Param(common.Name)
+	bad := func(msg string) error {
+		return c.JSON(http.StatusBadRequest, dtoCommon.NewBaseResponse("", msg, http.StatusBadRequest))
+	}
+	start, err := parseHistoryTime(c.QueryParam(common.Start))
+	if err != nil {
+		return bad("start 非法: " + err.Error())
+	}
+	end, err := parseHistoryTime(c.QueryParam(common.End))
+	if err != nil {
+		return bad("end 非法: " + err.Error())
+	}
+	if s := c.QueryParam("since"); s != "" {
+		since, err := parsePositiveDuration(s)
+		if err != nil {
+			return bad("since " + err.Error())
+		}
+		start = time.Now().Add(-since)
+	}
+	var step time.Duration
+	if s := c.QueryParam("step"); s != "" {
+		if step, err = parsePositiveDuration(s); err != nil {
+			return bad("step " + err.Error())
+		}
+	}
+	limit := 0
+	if s := c.QueryParam(common.Limit); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+			return bad("limit 非法: " + s)
+		}
+	}
+
+	resources := history.Resources(name)
+	if s := c.QueryParam("resource"); s != "" {
+		resources = strings.Split(s, ",")
+	}
+	resp := historyResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Device:       name,
+		Resources:    make([]resourceHistory, 0, len(resources)),
+	}
+	if step > 0 {
+		resp.Step = step.String()
+	}
+	for _, res := range resources {
+		samples := history.Query(name, res, start, end, limit)
+		rh := resourceHistory{Resource: res, Samples: samples}
+		if step > 0 {
+			rh.Samples, rh.Buckets = nil, history.Downsample(samples, step)
+		}
+		resp.Resources = append(resp.Resources, rh)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Unix 纳秒（与 core-data 一致）或 RFC3339，为空返回零值
+func parseHistoryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, n), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,72 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v4/dtos/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/lifecycle"
+)
+
+// 资源：设备当前生命周期状态
+const resLifecycleState = "lifecycleState"
+
+// 生命周期查询接口
+const (
+	apiLifecycleRoute     = common.ApiBase + "/lifecycle"
+	apiLifecycleNameRoute = apiLifecycleRoute + "/" + common.Name + "/:" + common.Name
+)
+
+type lifecyclesResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Devices                []lifecycle.Info `json:"devices"`
+}
+
+type lifecycleResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	lifecycle.Info
+	History []lifecycle.Transition `json:"history"`
+}
+
+// 状态变化写入 lifecycleState 资源
+func (d *WireSinkDriver) watchLifecycle() {
+	lifecycle.OnTransition(func(device string, t lifecycle.Transition) {
+		config.Devices().Set(device, resLifecycleState, string(t.To))
+		d.lc.Debugf("设备 %s 生命周期 %s → %s (%s) %s", device, t.From, t.To, t.Event, t.Detail)
+	})
+}
+
+func (d *WireSinkDriver) addLifecycleRoutes() error {
+	if err := d.sdk.AddCustomRoute(apiLifecycleRoute, interfaces.Authenticated, d.listLifecycle, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiLifecycleRoute, err)
+	}
+	if err := d.sdk.AddCustomRoute(apiLifecycleNameRoute, interfaces.Authenticated, d.getLifecycle, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiLifecycleNameRoute, err)
+	}
+	return nil
+}
+
+// GET /api/v3/lifecycle  各设备当前状态
+func (d *WireSinkDriver) listLifecycle(c echo.Context) error {
+	return c.JSON(http.StatusOK, lifecyclesResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Devices:      lifecycle.All(),
+	})
+}
+
+// GET /api/v3/lifecycle/name/:name  设备当前状态及转换记录
+func (d *WireSinkDriver) getLifecycle(c echo.Context) error {
+	name := c.Param(common.Name)
+	if _, ok := config.Devices().Values(name); !ok {
+		return c.JSON(http.StatusNotFound, dtoCommon.NewBaseResponse("", "未找到设备 "+name, http.StatusNotFound))
+	}
+	return c.JSON(http.StatusOK, lifecycleResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Info:         lifecycle.Current(name),
+		History:      lifecycle.History(name),
+	})
+}
@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"fmt"
+
+	bootstrapMessaging "github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/messaging"
+	bootstrapConfig "github.com/edgexfoundry/go-mod-bootstrap/v4/config"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-messaging/v4/messaging"
+	"github.com/edgexfoundry/go-mod-messaging/v4/pkg/types"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
+)
+
+// 与 SDK 自身连接区分的 ClientId 后缀，避免同一 broker 上 ClientId 冲突
+const sinkClientIdSuffix = "-sink"
+
+// 按 AuthMode 从 SecretStore 取凭据写入 Optional，与 SDK 的处理方式一致
+func (d *WireSinkDriver) setMessageBusAuth(info *bootstrapConfig.MessageBusInfo) error {
+	if info.AuthMode == "" || info.AuthMode == bootstrapMessaging.AuthModeNone {
+		return nil
+	}
+	secretData, err := bootstrapMessaging.GetSecretData(info.AuthMode, info.SecretName, d.sdk.SecretProvider())
+	if err != nil {
+		return fmt.Errorf("读取 MessageBus 凭据 %s 失败: %w", info.SecretName, err)
+	}
+	if err := bootstrapMessaging.ValidateSecretData(info.AuthMode, info.SecretName, secretData); err != nil {
+		return err
+	}
+	switch info.AuthMode {
+	case bootstrapMessaging.AuthModeUsernamePassword:
+		info.Optional[bootstrapMessaging.OptionsUsernameKey] = secretData.Username
+		info.Optional[bootstrapMessaging.OptionsPasswordKey] = secretData.Password
+	case bootstrapMessaging.AuthModeCert:
+		info.Optional[bootstrapMessaging.OptionsCertPEMBlockKey] = string(secretData.CertPemBlock)
+		info.Optional[bootstrapMessaging.OptionsKeyPEMBlockKey] = string(secretData.KeyPemBlock)
+	}
+	if len(secretData.CaPemBlock) > 0 {
+		info.Optional[bootstrapMessaging.OptionsCaPEMBlockKey] = string(secretData.CaPemBlock)
+	}
+	return nil
+}
+
+// 按 SDK 同源的 MessageBus 配置（mqtt / nats，含安全连接）另建一条连接收发汇聚网关数据
+// SDK 未公开其 MessageBus 客户端，ClientId 加后缀以免与 SDK 的连接冲突
+func (d *WireSinkDriver) newMessageBusTransport() (transport.Transport, error) {
+	info, err := d.loadMessageBusInfo()
+	if err != nil {
+		return nil, fmt.Errorf("读取 MessageBus 配置失败: %w", err)
+	}
+	optional := make(map[string]string, len(info.Optional)+1)
+	for k, v := range info.Optional {
+		optional[k] = v
+	}
+	if optional["ClientId"] == "" {
+		optional["ClientId"] = d.sdk.Name()
+	}
+	optional["ClientId"] += sinkClientIdSuffix
+	info.Optional = optional
+	if err := d.setMessageBusAuth(&info); err != nil {
+		return nil, err
+	}
+
+	client, err := messaging.NewMessageClient(types.MessageBusConfig{
+		Broker: types.HostInfo{
+			Host:     info.Host,
+			Port:     info.Port,
+			Protocol: info.Protocol,
+		},
+		Type:     info.Type,
+		Optional: info.Optional,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 MessageBus 客户端失败: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("连接 MessageBus %s://%s:%d 失败: %w", info.Protocol, info.Host, info.Port, err)
+	}
+	cfg := d.config().WireSink
+	prefix := info.GetBaseTopicPrefix()
+	d.lc.Infof("汇聚网关经 MessageBus(%s) %s://%s:%d 收发", info.Type, info.Protocol, info.Host, info.Port)
+	return transport.NewMessageBus(client,
+		common.BuildTopic(prefix, cfg.Writable.UpTopic),
+		common.BuildTopic(prefix, cfg.Writable.DownTopic),
+		cfg.SinkEid), nil
+}
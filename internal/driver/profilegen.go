@@ -0,0 +1,167 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+)
+
+// 自动生成的 Profile 名称后缀
+const generatedProfileSuffix = "-Generated-Profile"
+
+// 生成失败后，参量不变时的重试间隔
+const profileGenRetryInterval = 10 * time.Minute
+
+// 一次失败的生成
+type profileGenFailure struct {
+	at     time.Time
+	params int // 当时已观测的参量数，有新参量时立即重试
+}
+
+// paramMap 中 DataType → EdgeX ValueType
+var dataTypeToValueType = map[string]string{
+	"uint8":     common.ValueTypeUint8,
+	"uint16":    common.ValueTypeUint16,
+	"uint32":    common.ValueTypeUint32,
+	"int16":     common.ValueTypeInt16,
+	"float32":   common.ValueTypeFloat32,
+	"float32[]": common.ValueTypeFloat32Array,
+	"uint16[]":  common.ValueTypeUint16Array,
+	"":          common.ValueTypeObject, // 拓扑等结构化数据
+}
+
+// 是否开启自动生成 Profile
+func (d *WireSinkDriver) autoGenerateProfileEnabled() bool {
+	return d.config().WireSink.AutoGenerateProfile
+}
+
+// 检查设备已观测的参量中是否有当前 Profile 未覆盖的，有则在后台根据已观测参量生成 Profile。
+// 只比较 paramMap 中观测到的参量，派生值、查询结果等其他键不触发生成；
+// 解析协程只做内存比较，元数据调用在单独的协程中进行，同一设备同时只有一个生成任务。
+// 生成的 Profile 不会自动指定给设备：它只含观测到的参量，不含原 Profile 的命令资源，
+// 需运维人员审核、补充后再把设备改用该 Profile
+func (d *WireSinkDriver) checkUncoveredParams(deviceName string) {
+	if !d.autoGenerateProfileEnabled() {
+		return
+	}
+	params := config.GetObservedParams(deviceName)
+
+	d.profileGenMu.Lock()
+	defer d.profileGenMu.Unlock()
+	if d.profileGenRunning[deviceName] {
+		return
+	}
+	covered := d.generatedParams[deviceName]
+	pending := false
+	for _, p := range params {
+		if _, ok := d.sdk.DeviceResource(deviceName, p.Name); ok {
+			continue
+		}
+		if !covered[p.Name] {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		return
+	}
+	// 上次失败后没有新参量且未到重试时间，不再每帧重试
+	if f, ok := d.profileGenFailed[deviceName]; ok && f.params == len(params) && time.Since(f.at) < profileGenRetryInterval {
+		return
+	}
+	d.profileGenRunning[deviceName] = true
+	go d.runProfileGeneration(deviceName, params)
+}
+
+// 后台生成并上传 Profile，完成后更新生成记录
+func (d *WireSinkDriver) runProfileGeneration(deviceName string, params []config.ParamInfo) {
+	profileName, err := d.generateProfile(deviceName, params)
+
+	d.profileGenMu.Lock()
+	defer d.profileGenMu.Unlock()
+	delete(d.profileGenRunning, deviceName)
+	if err != nil {
+		d.profileGenFailed[deviceName] = profileGenFailure{at: time.Now(), params: len(params)}
+		d.lc.Errorf("设备 %s 自动生成 Profile 失败，%s 内参量不变时不再重试: %v", deviceName, profileGenRetryInterval, err)
+		return
+	}
+	delete(d.profileGenFailed, deviceName)
+	covered := make(map[string]bool, len(params))
+	for _, p := range params {
+		covered[p.Name] = true
+	}
+	d.generatedParams[deviceName] = covered
+	d.lc.Infof("设备 %s 存在未覆盖的参量，已生成 Profile %s；该 Profile 未指定给设备，审核后请手动将设备改用它", deviceName, profileName)
+}
+
+// 根据设备已上报过的参量生成 Profile 并通过 SDK 上传，已存在时更新
+func (d *WireSinkDriver) generateProfile(deviceName string, params []config.ParamInfo) (string, error) {
+	if len(params) == 0 {
+		return "", fmt.Errorf("设备 %s 尚未上报任何参量", deviceName)
+	}
+	profileName := deviceName + generatedProfileSuffix
+	profile := buildProfileFromParams(profileName, deviceName, params)
+
+	if existing, err := d.sdk.GetProfileByName(profileName); err == nil {
+		profile.Id = existing.Id
+		if err := d.sdk.UpdateDeviceProfile(profile); err != nil {
+			return "", fmt.Errorf("更新 Profile %s 失败: %w", profileName, err)
+		}
+	} else if _, err := d.sdk.AddDeviceProfile(profile); err != nil {
+		return "", fmt.Errorf("上传 Profile %s 失败: %w", profileName, err)
+	}
+	return profileName, nil
+}
+
+// 设备删除时清除生成记录
+func (d *WireSinkDriver) forgetGeneratedProfile(deviceName string) {
+	d.profileGenMu.Lock()
+	defer d.profileGenMu.Unlock()
+	delete(d.generatedParams, deviceName)
+	delete(d.profileGenFailed, deviceName)
+}
+
+// 把 ParamInfo 列表映射成 EdgeX DeviceProfile
+func buildProfileFromParams(profileName, deviceName string, params []config.ParamInfo) models.DeviceProfile {
+	resources := make([]models.DeviceResource, 0, len(params))
+	for _, p := range params {
+		valueType, ok := dataTypeToValueType[p.DataType]
+		if !ok {
+			valueType = common.ValueTypeString
+		}
+		resources = append(resources, models.DeviceResource{
+			Name:        p.Name,
+			Description: fmt.Sprintf("由设备 %s 上报的参量自动生成", deviceName),
+			Properties: models.ResourceProperties{
+				ValueType:    valueType,
+				ReadWrite:    common.ReadWrite_R,
+				Units:        p.Unit,
+				DefaultValue: defaultValueFor(valueType),
+			},
+		})
+	}
+	return models.DeviceProfile{
+		Name:            profileName,
+		Manufacturer:    "HY",
+		Model:           "generated",
+		Labels:          []string{"generated"},
+		Description:     fmt.Sprintf("根据设备 %s 已上报参量自动生成", deviceName),
+		DeviceResources: resources,
+	}
+}
+
+// 与现有 Profile 保持一致的默认值写法
+func defaultValueFor(valueType string) string {
+	switch valueType {
+	case common.ValueTypeObject:
+		return "{}"
+	case common.ValueTypeFloat32Array, common.ValueTypeUint16Array:
+		return "[0]"
+	case common.ValueTypeString:
+		return ""
+	}
+	return "0"
+}
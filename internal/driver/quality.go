@@ -0,0 +1,185 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+)
+
+// 读数 Tags：数据质量
+const (
+	tagQuality = "quality"
+
+	qualityGood        = "good"
+	qualityOutOfRange  = "out-of-range" // 超出范围，照常上报
+	qualityStale       = "stale"        // 传感器未响应，返回的是缓存值
+	qualitySubstituted = "substituted"  // 超出范围，已截到边界
+)
+
+// 资源属性：超出范围时的处理，取值同 WireSink.Writable.OutOfRange
+const outOfRangeAttribute = "outOfRange"
+
+const (
+	outOfRangeKeep  = "keep"
+	outOfRangeDrop  = "drop"
+	outOfRangeClamp = "clamp"
+)
+
+func validOutOfRange(s string) bool {
+	return s == outOfRangeKeep || s == outOfRangeDrop || s == outOfRangeClamp
+}
+
+// 资源属性优先，未设置或非法时取全局配置
+func (d *WireSinkDriver) outOfRangeAction(dr models.DeviceResource) string {
+	if s, ok := dr.Attributes[outOfRangeAttribute].(string); ok {
+		if validOutOfRange(s) {
+			return s
+		}
+		d.lc.Warnf("资源 %s 的 %s 属性非法: %q，按全局配置处理", dr.Name, outOfRangeAttribute, s)
+	}
+	return d.config().WireSink.Writable.OutOfRange
+}
+
+// Profile minimum/maximum，均未设置时返回 false
+func profileRange(dr models.DeviceResource) (config.Range, bool) {
+	r := config.Range{Min: math.Inf(-1), Max: math.Inf(1)}
+	if dr.Properties.Minimum != nil {
+		r.Min = *dr.Properties.Minimum
+	}
+	if dr.Properties.Maximum != nil {
+		r.Max = *dr.Properties.Maximum
+	}
+	return r, dr.Properties.Minimum != nil || dr.Properties.Maximum != nil
+}
+
+// 按范围检查一个数值：范围内为 good；否则 keep 照旧、drop 丢弃、clamp 截到边界（NaN 无法截取，丢弃）
+func checkRange(x float64, r config.Range, action string) (float64, string, bool) {
+	if r.Contains(x) {
+		return x, qualityGood, true
+	}
+	switch action {
+	case outOfRangeDrop:
+		return x, qualityOutOfRange, false
+	case outOfRangeClamp:
+		c := math.Min(math.Max(x, r.Min), r.Max)
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return x, qualityOutOfRange, false
+		}
+		return c, qualitySubstituted, true
+	}
+	return x, qualityOutOfRange, true
+}
+
+// 质量取较差的一个
+func worseQuality(a, b string) string {
+	rank := map[string]int{qualityGood: 0, qualitySubstituted: 1, qualityOutOfRange: 2, qualityStale: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// 换算解析值并检查范围：解析值对照参量表合理范围，换算后的值对照 Profile minimum/maximum。
+// 返回换算后的值及质量，值应丢弃时返回 false
+func (d *WireSinkDriver) convertChecked(deviceName, resourceName string, raw interface{}) (interface{}, string, bool) {
+	dr, hasDR := d.sdk.DeviceResource(deviceName, resourceName)
+	x, numeric := toFloat64(raw)
+	if !numeric {
+		return d.convertForRead(deviceName, resourceName, raw), qualityGood, true
+	}
+	action := d.config().WireSink.Writable.OutOfRange
+	if hasDR {
+		action = d.outOfRangeAction(dr)
+	}
+
+	r, ok := config.PlausibleRange(resourceName)
+	if !ok {
+		r = config.Range{Min: math.Inf(-1), Max: math.Inf(1)}
+	}
+	y, quality, keep := checkRange(x, r, action)
+	if !keep {
+		d.lc.Warnf("设备 %s 资源 %s 的值 %v 不合理（范围 [%g, %g]），丢弃", deviceName, resourceName, raw, r.Min, r.Max)
+		return nil, quality, false
+	}
+	if quality != qualityGood {
+		d.lc.Warnf("设备 %s 资源 %s 的值 %v 不合理（范围 [%g, %g]），质量标记为 %s", deviceName, resourceName, raw, r.Min, r.Max, quality)
+	}
+	if quality == qualitySubstituted {
+		raw = y
+	}
+	val := d.convertForRead(deviceName, resourceName, raw)
+
+	// 解析值已判为越限时不再重复检查
+	pr, ok := profileRange(dr)
+	if !hasDR || !ok || quality == qualityOutOfRange {
+		return d.retype(dr, val, quality), quality, true
+	}
+	v, _ := toFloat64(val)
+	v, q, keep := checkRange(v, pr, action)
+	if !keep {
+		d.lc.Warnf("设备 %s 资源 %s 的值 %v 超出 Profile 范围 [%g, %g]，丢弃", deviceName, resourceName, val, pr.Min, pr.Max)
+		return nil, q, false
+	}
+	if q != qualityGood {
+		d.lc.Warnf("设备 %s 资源 %s 的值 %v 超出 Profile 范围 [%g, %g]，质量标记为 %s", deviceName, resourceName, val, pr.Min, pr.Max, q)
+	}
+	if q == qualitySubstituted {
+		val = v
+	}
+	quality = worseQuality(quality, q)
+	return d.retype(dr, val, quality), quality, true
+}
+
+// 截取后的值为 float64，按 Profile 的 ValueType 还原类型
+func (d *WireSinkDriver) retype(dr models.DeviceResource, val interface{}, quality string) interface{} {
+	if quality != qualitySubstituted || dr.Properties.ValueType == "" {
+		return val
+	}
+	x, ok := toFloat64(val)
+	if !ok {
+		return val
+	}
+	out, err := conversion{valueType: dr.Properties.ValueType}.typed(x)
+	if err != nil {
+		d.lc.Warnf("资源 %s 截取后的值 %v 无法转为 %s: %v", dr.Name, x, dr.Properties.ValueType, err)
+		return val
+	}
+	return out
+}
+
+// 对一组值换算并检查范围，返回保留的值及各自的质量
+func (d *WireSinkDriver) convertValuesChecked(deviceName string, values map[string]interface{}) (map[string]interface{}, map[string]string) {
+	out := make(map[string]interface{}, len(values))
+	qualities := make(map[string]string, len(values))
+	for name, raw := range values {
+		val, quality, keep := d.convertChecked(deviceName, name, raw)
+		if !keep {
+			continue
+		}
+		out[name] = val
+		qualities[name] = quality
+	}
+	return out, qualities
+}
+
+// 为读数打上质量标记，已标记为 stale 的保持不变
+func markQuality(cv *dsModels.CommandValue, quality string) {
+	if cv.Tags == nil {
+		cv.Tags = make(map[string]string)
+	}
+	if cv.Tags[tagQuality] == qualityStale {
+		return
+	}
+	if quality == "" {
+		quality = qualityGood
+	}
+	cv.Tags[tagQuality] = quality
+}
+
+// 读取时范围检查未通过
+func errOutOfRange(deviceName, resourceName string, val interface{}) error {
+	return fmt.Errorf("设备 %s 资源 %s 的值 %v 超出合理范围，已按配置丢弃", deviceName, resourceName, val)
+}
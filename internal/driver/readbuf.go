@@ -0,0 +1,217 @@
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	dsModels "github.com/edgexfoundry/device-sdk-go/v4/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v4/dtos/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/readbuf"
+)
+
+// 上报缓冲状态接口
+const apiReadingBufferRoute = common.ApiBase + "/readingbuffer"
+
+const (
+	// 汇聚节点上的缓冲深度资源
+	resBufferedReadings = "bufferedReadings"
+	// 缓冲深度写到汇聚节点的间隔
+	bufferDepthInterval = 5 * time.Second
+)
+
+type readingBufferResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Enabled                bool           `json:"enabled"`
+	Buffer                 *readbuf.Stats `json:"buffer,omitempty"`
+}
+
+func (d *WireSinkDriver) addReadingBufferRoutes() error {
+	if err := d.sdk.AddCustomRoute(apiReadingBufferRoute, interfaces.Authenticated, d.queryReadingBuffer, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiReadingBufferRoute, err)
+	}
+	return nil
+}
+
+// GET /api/v3/readingbuffer  待交付条数、丢弃条数及最旧读数时间
+func (d *WireSinkDriver) queryReadingBuffer(c echo.Context) error {
+	resp := readingBufferResponse{BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK)}
+	if b := d.readings; b != nil {
+		st := b.Stats()
+		resp.Enabled, resp.Buffer = true, &st
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// 打开上报缓冲并启动交付协程，须在传输启动之前调用
+func (d *WireSinkDriver) startReadingBuffer() error {
+	cfg := d.config().WireSink
+	if cfg.ReadingBufferFile == "" {
+		return nil
+	}
+	b, err := readbuf.Open(cfg.ReadingBufferFile, cfg.ReadingBufferSize)
+	if err != nil {
+		return fmt.Errorf("打开上报缓冲失败: %w", err)
+	}
+	d.readings = b
+	d.readingsStop = make(chan struct{})
+	d.readingsDone = make(chan struct{})
+	go d.drainReadings(b)
+	go d.reportBufferDepth(b, cfg.SinkDeviceName)
+	return nil
+}
+
+// 停止交付并关闭缓冲，未交付的读数留在文件中下次启动补报
+func (d *WireSinkDriver) stopReadingBuffer() {
+	if d.readings == nil {
+		return
+	}
+	close(d.readingsStop)
+	<-d.readingsDone
+	if err := d.readings.Close(); err != nil {
+		d.lc.Errorf("关闭上报缓冲失败: %v", err)
+	}
+}
+
+// 按入队顺序逐条交给 SDK，SDK 阻塞时在此等待，读数留在缓冲中。
+// asyncCh 被 SDK 接收后即移出缓冲，其后发布失败的事件无法补报
+func (d *WireSinkDriver) drainReadings(b *readbuf.Buffer) {
+	defer close(d.readingsDone)
+	for {
+		r, err := b.Peek(d.readingsStop)
+		if errors.Is(err, readbuf.ErrClosed) {
+			return
+		}
+		if err != nil {
+			d.lc.Errorf("读取上报缓冲失败，跳过该条: %v", err)
+			b.Pop()
+			continue
+		}
+		av := &dsModels.AsyncValues{
+			DeviceName:    r.Device,
+			SourceName:    r.Source,
+			CommandValues: fromReading(r, d.lc.Errorf),
+		}
+		if len(av.CommandValues) == 0 {
+			b.Pop()
+			continue
+		}
+		select {
+		case d.asyncCh <- av:
+			b.Pop()
+			d.lc.Infof("AsyncValues pushed: device=%s source=%s count=%d",
+				r.Device, r.Source, len(av.CommandValues))
+		case <-d.readingsStop:
+			return
+		}
+	}
+}
+
+// 缓冲深度变化时写到汇聚节点的 bufferedReadings 资源
+func (d *WireSinkDriver) reportBufferDepth(b *readbuf.Buffer, sinkDevice string) {
+	ticker := time.NewTicker(bufferDepthInterval)
+	defer ticker.Stop()
+	last := -1
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.readingsStop:
+			return
+		}
+		n := b.Len()
+		if n == last {
+			continue
+		}
+		if last <= 0 && n > 0 {
+			d.lc.Warnf("SDK 上报阻塞，读数在本地缓冲排队: %d 条", n)
+		} else if last > 0 && n == 0 {
+			d.lc.Infof("上报缓冲已全部交付")
+		}
+		last = n
+		config.Devices().Set(sinkDevice, resBufferedReadings, int32(n))
+	}
+}
+
+// CommandValue 转为缓冲记录，值按 EdgeX ValueType 以 JSON 保存，Tags 一并保存；
+// 无法序列化的值（NaN、±Inf）记录日志后跳过
+func toReading(deviceName, sourceName string, origin int64, cvs []*dsModels.CommandValue, logf func(string, ...interface{})) readbuf.Reading {
+	r := readbuf.Reading{Device: deviceName, Source: sourceName, Origin: origin}
+	for _, cv := range cvs {
+		raw, err := json.Marshal(cv.Value)
+		if err != nil {
+			logf("缓冲读数 %s.%s 无法序列化，跳过该值: %v", deviceName, cv.DeviceResourceName, err)
+			continue
+		}
+		r.Values = append(r.Values, readbuf.Value{Name: cv.DeviceResourceName, Type: cv.Type, Value: raw, Tags: cv.Tags})
+	}
+	return r
+}
+
+// 缓冲记录还原为 CommandValue，Origin 保持入队时的时间
+func fromReading(r readbuf.Reading, logf func(string, ...interface{})) []*dsModels.CommandValue {
+	var cvs []*dsModels.CommandValue
+	for _, v := range r.Values {
+		val, err := decodeBuffered(v)
+		if err == nil {
+			var cv *dsModels.CommandValue
+			if cv, err = dsModels.NewCommandValue(v.Name, v.Type, val); err == nil {
+				cv.Origin = r.Origin
+				cv.Tags = v.Tags
+				cvs = append(cvs, cv)
+				continue
+			}
+		}
+		logf("还原缓冲读数 %s.%s 失败: %v", r.Device, v.Name, err)
+	}
+	return cvs
+}
+
+// 与 AsyncReporting 支持的类型一致
+func decodeBuffered(v readbuf.Value) (interface{}, error) {
+	var err error
+	switch v.Type {
+	case common.ValueTypeInt32:
+		var x int32
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeInt64:
+		var x int64
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeUint8:
+		var x uint8
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeUint16:
+		var x uint16
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeUint32:
+		var x uint32
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeFloat32:
+		var x float32
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeFloat64:
+		var x float64
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeString:
+		var x string
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	case common.ValueTypeBool:
+		var x bool
+		err = json.Unmarshal(v.Value, &x)
+		return x, err
+	}
+	return nil, fmt.Errorf("不支持的类型 %s", v.Type)
+}
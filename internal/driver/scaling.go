@@ -0,0 +1,226 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+)
+
+// 资源属性：工程量换算。上报/读取值 = 解析值 × scale + offset，写入时反向换算
+const (
+	scaleAttribute  = "scale"
+	offsetAttribute = "offset"
+	// 目标单位，如 "A"；未设置 scale 时按 SI 词头由解析单位推算，如 kA → A 为 1000
+	unitAttribute = "unit"
+)
+
+// SI 词头倍数
+var unitPrefixes = map[string]float64{
+	"G": 1e9, "M": 1e6, "k": 1e3, "h": 1e2,
+	"d": 1e-1, "c": 1e-2, "m": 1e-3, "μ": 1e-6, "u": 1e-6, "n": 1e-9,
+}
+
+// 资源的换算参数
+type conversion struct {
+	scale     float64
+	offset    float64
+	unit      string // 换算后的单位，未设置时为空
+	valueType string // Profile 中的 ValueType
+}
+
+// 资源属性中的换算参数，scale、offset、unit 均未设置时返回 false
+func conversionOf(dr models.DeviceResource) (conversion, bool, error) {
+	c := conversion{scale: 1, valueType: dr.Properties.ValueType}
+	rawScale, hasScale := dr.Attributes[scaleAttribute]
+	rawOffset, hasOffset := dr.Attributes[offsetAttribute]
+	rawUnit, hasUnit := dr.Attributes[unitAttribute]
+	if !hasScale && !hasOffset && !hasUnit {
+		return c, false, nil
+	}
+	if hasScale {
+		v, ok := toFloat64(rawScale)
+		if !ok || v == 0 {
+			return c, false, fmt.Errorf("资源 %s 的 %s 属性非法: %v", dr.Name, scaleAttribute, rawScale)
+		}
+		c.scale = v
+	}
+	if hasOffset {
+		v, ok := toFloat64(rawOffset)
+		if !ok {
+			return c, false, fmt.Errorf("资源 %s 的 %s 属性非法: %v", dr.Name, offsetAttribute, rawOffset)
+		}
+		c.offset = v
+	}
+	if hasUnit {
+		c.unit, _ = rawUnit.(string)
+		if !hasScale {
+			info, ok := config.ParamByName(dr.Name)
+			if !ok {
+				return c, false, fmt.Errorf("资源 %s 不是已知参量，%s 属性需配合 %s 使用", dr.Name, unitAttribute, scaleAttribute)
+			}
+			f, ok := unitFactor(info.Unit, c.unit)
+			if !ok {
+				return c, false, fmt.Errorf("资源 %s 无法由 %q 换算到 %q，请设置 %s", dr.Name, info.Unit, c.unit, scaleAttribute)
+			}
+			c.scale = f
+		}
+	}
+	return c, true, nil
+}
+
+// from → to 的倍数，两者去掉 SI 词头后须为同一单位
+func unitFactor(from, to string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	for _, a := range splitUnit(from) {
+		for _, b := range splitUnit(to) {
+			if a.base == b.base {
+				return a.factor / b.factor, true
+			}
+		}
+	}
+	return 0, false
+}
+
+type unitPart struct {
+	factor float64
+	base   string
+}
+
+// 单位的可能拆分：不带词头，以及首字符为词头时的词头 + 基本单位
+func splitUnit(u string) []unitPart {
+	parts := []unitPart{{1, u}}
+	for p, f := range unitPrefixes {
+		if rest, ok := strings.CutPrefix(u, p); ok && rest != "" {
+			parts = append(parts, unitPart{f, rest})
+		}
+	}
+	return parts
+}
+
+// 解析值换算为工程量，按 Profile 的 ValueType 输出；非数值原样返回
+func (c conversion) apply(val interface{}) (interface{}, error) {
+	x, ok := toFloat64(val)
+	if !ok {
+		return val, nil
+	}
+	return c.typed(x*c.scale + c.offset)
+}
+
+// 工程量反向换算为解析单位下的值，已知参量按参量表的数据类型保存，与解析结果一致
+func (c conversion) reverse(resourceName string, val interface{}) (interface{}, error) {
+	x, ok := toFloat64(val)
+	if !ok {
+		return nil, fmt.Errorf("值 %v 不是数值", val)
+	}
+	raw := (x - c.offset) / c.scale
+	info, ok := config.ParamByName(resourceName)
+	if !ok {
+		return raw, nil
+	}
+	vt, ok := dataTypeToValueType[info.DataType]
+	if !ok || strings.HasSuffix(vt, "Array") || vt == common.ValueTypeObject {
+		return raw, nil
+	}
+	if vt != common.ValueTypeFloat32 {
+		raw = math.Round(raw)
+	}
+	return coerceTo(raw, vt)
+}
+
+func (c conversion) typed(x float64) (interface{}, error) {
+	if strings.HasPrefix(c.valueType, "Int") || strings.HasPrefix(c.valueType, "Uint") {
+		x = math.Round(x)
+	}
+	if c.valueType == "" {
+		return x, nil
+	}
+	return coerceTo(x, c.valueType)
+}
+
+// 按设备资源属性换算一个值，无换算属性时原样返回
+func (d *WireSinkDriver) convertForRead(deviceName, resourceName string, val interface{}) interface{} {
+	dr, ok := d.sdk.DeviceResource(deviceName, resourceName)
+	if !ok {
+		return val
+	}
+	c, ok, err := conversionOf(dr)
+	if err != nil {
+		d.lc.Warnf("设备 %s: %v，不换算", deviceName, err)
+	}
+	if !ok {
+		return val
+	}
+	out, err := c.apply(val)
+	if err != nil {
+		d.lc.Warnf("设备 %s 资源 %s 换算 %v 失败: %v", deviceName, resourceName, val, err)
+		return val
+	}
+	return out
+}
+
+// 对一组值逐个换算，返回新的 map
+func (d *WireSinkDriver) convertValuesForRead(deviceName string, values map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for name, val := range values {
+		out[name] = d.convertForRead(deviceName, name, val)
+	}
+	return out
+}
+
+// 核对 Profile 中各资源的单位与参量表是否一致：
+// 换算后的单位（unit 属性或参量表单位）应与资源 units 相同，否则告警
+func (d *WireSinkDriver) checkProfileUnits(profile models.DeviceProfile) {
+	for _, dr := range profile.DeviceResources {
+		info, known := config.ParamByName(dr.Name)
+		c, hasConv, err := conversionOf(dr)
+		if err != nil {
+			d.lc.Warnf("Profile %s: %v", profile.Name, err)
+			continue
+		}
+		if !known {
+			continue
+		}
+		want := info.Unit
+		if hasConv && c.unit != "" {
+			want = c.unit
+		}
+		units := dr.Properties.Units
+		if units == "" || units == want {
+			continue
+		}
+		if hasConv && c.unit == "" {
+			// 只设置了 scale/offset，由使用者保证单位
+			continue
+		}
+		if f, ok := unitFactor(info.Unit, units); !hasConv && ok {
+			d.lc.Warnf("Profile %s 资源 %s 单位为 %s，参量表为 %s，缺少换算属性（%s: %q 或 %s: %g）",
+				profile.Name, dr.Name, units, info.Unit, unitAttribute, units, scaleAttribute, f)
+			continue
+		}
+		d.lc.Warnf("Profile %s 资源 %s 单位 %q 与换算后的单位 %q 不一致", profile.Name, dr.Name, units, want)
+	}
+}
+
+// 核对全部设备所用 Profile 的单位及派生资源
+func (d *WireSinkDriver) checkUnits() {
+	checked := make(map[string]bool)
+	for _, dev := range d.sdk.Devices() {
+		if checked[dev.ProfileName] {
+			continue
+		}
+		checked[dev.ProfileName] = true
+		profile, err := d.sdk.GetProfileByName(dev.ProfileName)
+		if err != nil {
+			d.lc.Warnf("获取 Profile %s 失败，跳过单位核对: %v", dev.ProfileName, err)
+			continue
+		}
+		d.checkProfileUnits(profile)
+		d.checkDerivedResources(profile)
+	}
+}
@@ -0,0 +1,117 @@
+package driver
+
+import (
+	"math"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
+)
+
+func resource(name, valueType string, attrs map[string]interface{}) models.DeviceResource {
+	return models.DeviceResource{
+		Name:       name,
+		Properties: models.ResourceProperties{ValueType: valueType},
+		Attributes: attrs,
+	}
+}
+
+func TestUnitFactor(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     float64
+		ok       bool
+	}{
+		{"kA", "A", 1000, true},
+		{"A", "kA", 0.001, true},
+		{"mV", "V", 0.001, true},
+		{"MPa", "kPa", 1000, true},
+		{"m", "m", 1, true},
+		{"mm", "m", 0.001, true},
+		{"kA", "V", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := unitFactor(c.from, c.to)
+		if ok != c.ok || (ok && math.Abs(got-c.want) > c.want*1e-9) {
+			t.Errorf("unitFactor(%q, %q) = %v, %v，期望 %v, %v", c.from, c.to, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestConversionOf(t *testing.T) {
+	if _, ok, err := conversionOf(resource("PrimaryCurrent", common.ValueTypeFloat32, nil)); ok || err != nil {
+		t.Fatalf("无换算属性时 ok=%v err=%v", ok, err)
+	}
+	// 只设 unit：按参量表单位 kA 推算
+	c, ok, err := conversionOf(resource("PrimaryCurrent", common.ValueTypeFloat32, map[string]interface{}{unitAttribute: "A"}))
+	if err != nil || !ok || c.scale != 1000 || c.unit != "A" {
+		t.Fatalf("conversion = %+v, ok=%v, err=%v", c, ok, err)
+	}
+	if _, _, err := conversionOf(resource("PrimaryCurrent", common.ValueTypeFloat32, map[string]interface{}{scaleAttribute: 0})); err == nil {
+		t.Fatal("scale 为 0 应报错")
+	}
+	if _, _, err := conversionOf(resource("PrimaryCurrent", common.ValueTypeFloat32, map[string]interface{}{unitAttribute: "V"})); err == nil {
+		t.Fatal("kA 无法换算到 V 应报错")
+	}
+	if _, _, err := conversionOf(resource("NotAParam", common.ValueTypeFloat32, map[string]interface{}{unitAttribute: "A"})); err == nil {
+		t.Fatal("未知参量只设 unit 应报错")
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	// kA → A
+	kA, _, err := conversionOf(resource("PrimaryCurrent", common.ValueTypeFloat32, map[string]interface{}{unitAttribute: "A"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := kA.apply(float32(1.25))
+	if err != nil || v != float32(1250) {
+		t.Fatalf("apply(1.25 kA) = %v (%T), err=%v", v, v, err)
+	}
+	raw, err := kA.reverse("PrimaryCurrent", float32(1250))
+	if err != nil || raw != float32(1.25) {
+		t.Fatalf("reverse(1250 A) = %v (%T), err=%v", raw, raw, err)
+	}
+
+	// 带 offset：摄氏度 → 开尔文
+	k, _, err := conversionOf(resource("Temperature", common.ValueTypeFloat64, map[string]interface{}{offsetAttribute: 273.15}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = k.apply(float32(25))
+	if err != nil || math.Abs(v.(float64)-298.15) > 1e-9 {
+		t.Fatalf("apply(25 ℃) = %v, err=%v", v, err)
+	}
+	raw, err = k.reverse("Temperature", 298.15)
+	if err != nil || math.Abs(float64(raw.(float32))-25) > 1e-4 {
+		t.Fatalf("reverse(298.15 K) = %v (%T), err=%v", raw, raw, err)
+	}
+
+	// 整数型资源换算后取整
+	i, _, err := conversionOf(resource("Time", common.ValueTypeInt32, map[string]interface{}{scaleAttribute: 0.5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err = i.apply(uint32(5)); err != nil || v != int32(3) {
+		t.Fatalf("apply(5 × 0.5) = %v (%T), err=%v", v, v, err)
+	}
+	if raw, err = i.reverse("Time", 3); err != nil || raw != uint32(6) {
+		t.Fatalf("reverse(3 / 0.5) = %v (%T), err=%v", raw, raw, err)
+	}
+	if _, err := i.reverse("Time", "abc"); err == nil {
+		t.Fatal("非数值应报错")
+	}
+}
+
+func TestEncodeParamValue(t *testing.T) {
+	b, err := encodeParamValue(float32(1.5), 4)
+	if err != nil || len(b) != 4 || math.Float32frombits(uint32(b[0])|uint32(b[1])<<8|uint32(b[2])<<16|uint32(b[3])<<24) != 1.5 {
+		t.Fatalf("encode float32 = % X, err=%v", b, err)
+	}
+	if b, err := encodeParamValue(uint8(7), 1); err != nil || len(b) != 1 || b[0] != 7 {
+		t.Fatalf("encode uint8 = % X, err=%v", b, err)
+	}
+	if _, err := encodeParamValue(uint16(7), 1); err == nil {
+		t.Fatal("长度不符应报错")
+	}
+}
@@ -0,0 +1,78 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	bootstrapMessaging "github.com/edgexfoundry/go-mod-bootstrap/v4/bootstrap/messaging"
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
+)
+
+// 按认证方式从 SecretStore 读取网关 broker 凭据，写入连接参数
+// Secret 的键与 EdgeX MessageBus 一致：username、password、clientcert、clientkey、cacert
+func (d *WireSinkDriver) setBrokerAuth(opts *mqttclient.Options, authMode, secretName string) error {
+	if authMode == bootstrapMessaging.AuthModeNone {
+		return nil
+	}
+	secretData, err := bootstrapMessaging.GetSecretData(authMode, secretName, d.sdk.SecretProvider())
+	if err != nil {
+		return fmt.Errorf("读取 broker 凭据 %s 失败: %w", secretName, err)
+	}
+	if err := bootstrapMessaging.ValidateSecretData(authMode, secretName, secretData); err != nil {
+		return err
+	}
+	switch authMode {
+	case bootstrapMessaging.AuthModeUsernamePassword:
+		opts.Username = secretData.Username
+		opts.Password = secretData.Password
+	case bootstrapMessaging.AuthModeCert:
+		opts.ClientCert = secretData.CertPemBlock
+		opts.ClientKey = secretData.KeyPemBlock
+	}
+	if len(secretData.CaPemBlock) > 0 {
+		opts.CACert = secretData.CaPemBlock
+	}
+	return nil
+}
+
+// 当前传输使用的凭据名称，无需凭据时为空
+func (d *WireSinkDriver) transportSecretName() string {
+	cfg := d.config()
+	switch strings.ToLower(cfg.WireSink.Transport) {
+	case transport.NameMQTT, transport.NameMQTT5:
+		if cfg.WireSink.BrokerAuthMode != bootstrapMessaging.AuthModeNone {
+			return cfg.WireSink.BrokerSecretName
+		}
+	case transport.NameMessageBus:
+		info, err := d.loadMessageBusInfo()
+		if err != nil {
+			d.lc.Warnf("读取 MessageBus 配置失败，不监听其凭据: %v", err)
+			return ""
+		}
+		if info.AuthMode != "" && info.AuthMode != bootstrapMessaging.AuthModeNone {
+			return info.SecretName
+		}
+	}
+	return ""
+}
+
+// 监听凭据更新：证书或密码轮换后重建传输，以新凭据重新连接
+func (d *WireSinkDriver) watchTransportSecret() {
+	name := d.transportSecretName()
+	if name == "" {
+		return
+	}
+	if err := d.sdk.SecretProvider().RegisterSecretUpdatedCallback(name, d.onTransportSecretUpdated); err != nil {
+		d.lc.Warnf("无法监听凭据 %s 的更新: %v", name, err)
+		return
+	}
+	d.lc.Infof("已监听凭据 %s 的更新", name)
+}
+
+func (d *WireSinkDriver) onTransportSecretUpdated(secretName string) {
+	d.lc.Infof("凭据 %s 已更新，重建传输", secretName)
+	if err := d.restartTransport(); err != nil {
+		d.lc.Errorf("凭据更新后重建传输失败: %v", err)
+	}
+}
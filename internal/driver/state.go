@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/persist"
+)
+
+// 从快照恢复运行时状态，须在在线检查启动之前调用，恢复的值不会触发变化事件
+func (d *WireSinkDriver) restoreState() {
+	cfg := d.config().WireSink
+	if cfg.StateFile == "" {
+		return
+	}
+	retention, _ := parsePositiveDuration(cfg.StateRetention)
+	st, err := persist.Load(cfg.StateFile, retention)
+	if err != nil {
+		d.lc.Warnf("恢复运行时状态失败，按默认值启动: %v", err)
+		return
+	}
+	d.lc.Infof("已从 %s 恢复运行时状态: 资源值 %d, EID 映射 %d, 拓扑节点 %d, 暂存下行 %d, 分片缓存 %d",
+		cfg.StateFile, st.Values, st.Eids, st.Topology, st.Held, st.Fragments)
+}
+
+// 定期保存快照，Stop 时停止并再保存一次
+func (d *WireSinkDriver) startStateSaver() {
+	cfg := d.config().WireSink
+	if cfg.StateFile == "" {
+		return
+	}
+	interval, _ := parsePositiveDuration(cfg.StateSaveInterval)
+	d.stateStop = make(chan struct{})
+	d.stateDone = make(chan struct{})
+	go func() {
+		defer close(d.stateDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.saveState(cfg.StateFile)
+			case <-d.stateStop:
+				d.saveState(cfg.StateFile)
+				return
+			}
+		}
+	}()
+}
+
+func (d *WireSinkDriver) stopStateSaver() {
+	if d.stateStop == nil {
+		return
+	}
+	close(d.stateStop)
+	<-d.stateDone
+	d.stateStop = nil
+}
+
+func (d *WireSinkDriver) saveState(path string) {
+	if err := persist.Save(path); err != nil {
+		d.lc.Errorf("保存运行时状态到 %s 失败: %v", path, err)
+	}
+}
@@ -1,49 +1,272 @@
 package driver
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
 	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/lifecycle"
+	"github.com/linjuya-lu/device-wiresink-go/internal/sfqueue"
 )
 
-func startHealthCheckLoop() {
+// 在线检查用到的资源
+const (
+	resLastDataTimestamp = "lastDataTimestamp"
+	resPeriod            = "period"
+	// 未配置 period 时取传感器上报的数据采集间隔
+	resDataCollectionInterval = "DataCollectionInterval"
+	resState                  = "state"
+)
+
+// 一次在线状态变化
+type healthTransition struct {
+	Device   string    `json:"device"`
+	Up       bool      `json:"up"`
+	LastData time.Time `json:"lastData"`
+	Period   string    `json:"period"`
+	// 期望周期的来源：period / DataCollectionInterval
+	PeriodSource string `json:"periodSource"`
+}
+
+// 设备在线检查：订阅数据时间和上报周期的变化，收到数据即置为正常并重新计时，
+// 超过 1.5 个周期未收到数据记为迟到，超过 2 个周期置为故障；状态变化时回调 onChange
+// 同时驱动生命周期状态机的上行、休眠、迟到和超时事件
+type healthMonitor struct {
+	sub      *config.Subscription
+	onChange func(healthTransition)
+	mu       sync.Mutex
+	timers   map[string][]*time.Timer
+	up       map[string]bool
+
+	// 待回调的状态变化：按设备只保留最新一次，由 notify 协程串行回调，
+	// onChange 中的元数据调用不阻塞订阅处理和计时器
+	pending map[string]healthTransition
+	order   []string
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func startHealthMonitor(onChange func(healthTransition)) *healthMonitor {
+	h := &healthMonitor{
+		sub:      config.Devices().SubscribeLatest("", ""),
+		onChange: onChange,
+		timers:   make(map[string][]*time.Timer),
+		up:       make(map[string]bool),
+		pending:  make(map[string]healthTransition),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go h.notify()
+	for _, dev := range config.Devices().Names() {
+		h.rearm(dev, false)
+	}
 	go func() {
-		ticker := time.NewTicker(time.Second)
-		defer ticker.Stop()
+		for c := range h.sub.C {
+			switch {
+			case c.Removed:
+				h.stop(c.Device)
+				lifecycle.Remove(c.Device)
+			case c.Resource == resLastDataTimestamp:
+				h.rearm(c.Device, true)
+			case c.Resource == resPeriod || c.Resource == resDataCollectionInterval:
+				h.rearm(c.Device, false)
+			}
+		}
+	}()
+	return h
+}
+
+// 停止检查
+func (h *healthMonitor) Close() {
+	h.sub.Close()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for dev := range h.timers {
+		h.stopLocked(dev)
+	}
+	close(h.done)
+}
+
+// 期望的上报周期：优先 period，为 0 或未配置时取 DataCollectionInterval
+func expectedPeriod(dev string) (time.Duration, string, bool) {
+	if p, ok := config.Devices().Uint32(dev, resPeriod); ok && p > 0 {
+		return time.Duration(p) * time.Second, resPeriod, true
+	}
+	v, _ := config.Devices().Get(dev, resDataCollectionInterval)
+	if p, ok := v.(uint16); ok && p > 0 {
+		return time.Duration(p) * time.Second, resDataCollectionInterval, true
+	}
+	return 0, "", false
+}
 
-		for range ticker.C {
-			now := time.Now().UnixNano() // 当前系统时间，纳秒
+// 按最近一次数据时间和期望周期重新计时，uplink 表示刚收到上行；
+// 从未收到数据或周期未知时只处理上行事件
+func (h *healthMonitor) rearm(dev string, uplink bool) {
+	h.mu.Lock()
+	h.stopLocked(dev)
+	h.mu.Unlock()
 
-			// 读取设备名称
-			config.Mu.RLock()
-			deviceNames := make([]string, 0, len(config.ValuesMap))
-			for dev := range config.ValuesMap {
-				deviceNames = append(deviceNames, dev)
+	if uplink {
+		lifecycle.Fire(dev, lifecycle.EventUplink, "")
+		// 休眠传感器接收窗口结束后转入休眠
+		if eid, ok := config.Devices().Get(dev, config.EIDResource); ok {
+			if window, sleepy := sfqueue.ReceiveWindow(fmt.Sprint(eid)); sleepy {
+				h.after(dev, window, func() { lifecycle.Fire(dev, lifecycle.EventSleep, "") })
 			}
-			config.Mu.RUnlock()
-
-			// 逐个检查每台设备
-			for _, dev := range deviceNames {
-				rawTs, okTs := config.GetDeviceValue(dev, "lastDataTimestamp")
-				rawPr, okPr := config.GetDeviceValue(dev, "period")
-				if !okTs || !okPr {
-					continue
-				}
-				// 断言
-				lastTs, ok1 := rawTs.(int64)
-				period, ok2 := rawPr.(uint32)
-				if !ok1 || !ok2 {
-					continue
-				}
-				// 判断是否超时： now - lastTs > 2 * period 秒
-				deadline := int64(period) * 2 * int64(time.Second)
-				newState := uint8(0)
-				if now-lastTs > deadline {
-					newState = 1
-				}
-				// 写回 state
-				config.SetDeviceValue(dev, "state", newState)
+		}
+	}
+
+	lastTs, ok := config.Devices().Int64(dev, resLastDataTimestamp)
+	if !ok || lastTs == 0 {
+		return
+	}
+	period, source, ok := expectedPeriod(dev)
+	if !ok {
+		return
+	}
+	t := healthTransition{
+		Device:       dev,
+		LastData:     time.Unix(0, lastTs),
+		Period:       period.String(),
+		PeriodSource: source,
+	}
+	detail := fmt.Sprintf("期望周期 %s（%s）", period, source)
+	if late := time.Until(t.LastData.Add(period * 3 / 2)); late > 0 {
+		h.after(dev, late, func() { lifecycle.Fire(dev, lifecycle.EventLate, detail) })
+	}
+	remaining := time.Until(t.LastData.Add(2 * period))
+	if remaining > 0 {
+		down := t
+		h.after(dev, remaining, func() {
+			if h.set(down) {
+				lifecycle.Fire(dev, lifecycle.EventTimeout, detail)
+			}
+		})
+	}
+	// 已超时的设备在周期变化时重新计时，仅在由正常转为故障时触发超时事件，
+	// 避免把复位、设置等之后的状态又拉回离线
+	t.Up = remaining > 0
+	if h.set(t) && !t.Up {
+		lifecycle.Fire(dev, lifecycle.EventTimeout, detail)
+	}
+}
+
+func (h *healthMonitor) after(dev string, d time.Duration, fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.timers[dev] = append(h.timers[dev], time.AfterFunc(d, fn))
+}
+
+func (h *healthMonitor) stop(dev string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopLocked(dev)
+	delete(h.up, dev)
+	if _, ok := h.pending[dev]; ok {
+		delete(h.pending, dev)
+		for i, d := range h.order {
+			if d == dev {
+				h.order = append(h.order[:i], h.order[i+1:]...)
+				break
 			}
 		}
-	}()
+	}
+}
+
+func (h *healthMonitor) stopLocked(dev string) {
+	for _, t := range h.timers[dev] {
+		t.Stop()
+	}
+	delete(h.timers, dev)
+}
+
+// 写回 state（0=正常，1=故障），状态变化时回调并返回 true
+func (h *healthMonitor) set(t healthTransition) bool {
+	state := uint8(1)
+	if t.Up {
+		state = 0
+	}
+	if cur, ok := config.Devices().Uint8(t.Device, resState); !ok || cur != state {
+		config.Devices().Set(t.Device, resState, state)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev, known := h.up[t.Device]
+	h.up[t.Device] = t.Up
+	changed := !known || prev != t.Up
+	if changed && h.onChange != nil {
+		if _, ok := h.pending[t.Device]; !ok {
+			h.order = append(h.order, t.Device)
+		}
+		h.pending[t.Device] = t
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+	return changed
+}
+
+// 依次回调待处理的状态变化
+func (h *healthMonitor) notify() {
+	for {
+		h.mu.Lock()
+		if len(h.order) == 0 {
+			h.mu.Unlock()
+			select {
+			case <-h.wake:
+				continue
+			case <-h.done:
+				return
+			}
+		}
+		dev := h.order[0]
+		h.order = h.order[1:]
+		t := h.pending[dev]
+		delete(h.pending, dev)
+		h.mu.Unlock()
+		h.onChange(t)
+	}
+}
+
+// 系统事件 action：设备在线状态变化
+const systemEventActionOperatingState = "operatingstate"
+
+// 把在线状态同步为 EdgeX 设备 OperatingState（UP/DOWN），变化时发布系统事件
+// 不在 Core Metadata 中的设备（仅 devices.yaml 中配置）只记录日志
+func (d *WireSinkDriver) syncOperatingState(t healthTransition) {
+	state := models.OperatingState(models.Down)
+	if t.Up {
+		state = models.Up
+	}
+	dev, err := d.sdk.GetDeviceByName(t.Device)
+	if err != nil {
+		d.lc.Debugf("设备 %s 不在元数据中，跳过 OperatingState 同步: %v", t.Device, err)
+		return
+	}
+	if dev.OperatingState == state {
+		return
+	}
+	if err := d.sdk.UpdateDeviceOperatingState(t.Device, state); err != nil {
+		d.lc.Errorf("更新设备 %s OperatingState 为 %s 失败: %v", t.Device, state, err)
+		return
+	}
+	d.lc.Infof("设备 %s OperatingState %s → %s（最近数据 %s，期望周期 %s 来自 %s）",
+		t.Device, dev.OperatingState, state, t.LastData.Format(time.RFC3339), t.Period, t.PeriodSource)
+	d.sdk.PublishGenericSystemEvent(common.DeviceSystemEventType, systemEventActionOperatingState, operatingStateEvent{
+		healthTransition: t,
+		OperatingState:   state,
+		Previous:         dev.OperatingState,
+	})
+}
+
+// 在线状态变化系统事件的内容
+type operatingStateEvent struct {
+	healthTransition
+	OperatingState models.OperatingState `json:"operatingState"`
+	Previous       models.OperatingState `json:"previous"`
 }
@@ -0,0 +1,45 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/lifecycle"
+)
+
+// 已超时的设备在周期变化时不再重复触发超时，只有由正常转为故障时才触发
+func TestHealthTimeoutOnlyOnChange(t *testing.T) {
+	const dev = "health-sensor"
+	config.Devices().Set(dev, resPeriod, uint32(10))
+	config.Devices().Set(dev, resLastDataTimestamp, time.Now().Add(-time.Hour).UnixNano())
+	defer func() {
+		config.Devices().Delete(dev)
+		lifecycle.Remove(dev)
+	}()
+
+	h := startHealthMonitor(nil)
+	defer h.Close()
+	if s := lifecycle.Current(dev).State; s != lifecycle.StateOffline {
+		t.Fatalf("超时设备状态 = %s，期望 offline", s)
+	}
+
+	// 离线后下发复位，之后周期变化重新计时不应把状态拉回离线
+	lifecycle.Fire(dev, lifecycle.EventReset, "")
+	h.rearm(dev, false)
+	if s := lifecycle.Current(dev).State; s != lifecycle.StateResetting {
+		t.Fatalf("周期变化后状态 = %s，期望仍为 resetting", s)
+	}
+
+	// 恢复上报后再次超时，照常触发
+	config.Devices().Set(dev, resLastDataTimestamp, time.Now().UnixNano())
+	h.rearm(dev, true)
+	if s := lifecycle.Current(dev).State; s != lifecycle.StateOnboarding {
+		t.Fatalf("上行后状态 = %s，期望 onboarding", s)
+	}
+	config.Devices().Set(dev, resLastDataTimestamp, time.Now().Add(-time.Hour).UnixNano())
+	h.rearm(dev, false)
+	if s := lifecycle.Current(dev).State; s != lifecycle.StateOffline {
+		t.Fatalf("再次超时后状态 = %s，期望 offline", s)
+	}
+}
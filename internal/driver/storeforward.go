@@ -0,0 +1,55 @@
+package driver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v4/dtos/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device-wiresink-go/internal/sfqueue"
+)
+
+// 休眠传感器暂存下行的查询、取消接口
+const (
+	apiStoreForwardRoute   = common.ApiBase + "/storeforward"
+	apiStoreForwardIdRoute = apiStoreForwardRoute + "/" + common.Id + "/:" + common.Id
+)
+
+type storeForwardResponse struct {
+	dtoCommon.BaseResponse `json:",inline"`
+	Pending                []sfqueue.Item `json:"pending"`
+}
+
+func (d *WireSinkDriver) addStoreForwardRoutes() error {
+	if err := d.sdk.AddCustomRoute(apiStoreForwardRoute, interfaces.Authenticated, d.queryStoreForward, http.MethodGet); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiStoreForwardRoute, err)
+	}
+	if err := d.sdk.AddCustomRoute(apiStoreForwardIdRoute, interfaces.Authenticated, d.cancelStoreForward, http.MethodDelete); err != nil {
+		return fmt.Errorf("注册 %s 失败: %w", apiStoreForwardIdRoute, err)
+	}
+	return nil
+}
+
+// GET /api/v3/storeforward?eid=  暂存待发的下行，按下发顺序排列
+func (d *WireSinkDriver) queryStoreForward(c echo.Context) error {
+	pending := sfqueue.Pending(c.QueryParam("eid"))
+	if pending == nil {
+		pending = []sfqueue.Item{}
+	}
+	return c.JSON(http.StatusOK, storeForwardResponse{
+		BaseResponse: dtoCommon.NewBaseResponse("", "", http.StatusOK),
+		Pending:      pending,
+	})
+}
+
+// DELETE /api/v3/storeforward/id/:id  取消一条暂存下行，下行跟踪记为 failed
+func (d *WireSinkDriver) cancelStoreForward(c echo.Context) error {
+	id := c.Param(common.Id)
+	if !sfqueue.Cancel(id) {
+		return c.JSON(http.StatusNotFound, dtoCommon.NewBaseResponse("", "未找到暂存下行 "+id, http.StatusNotFound))
+	}
+	d.lc.Infof("已取消暂存下行 %s", id)
+	return c.JSON(http.StatusOK, dtoCommon.NewBaseResponse("", "", http.StatusOK))
+}
@@ -0,0 +1,209 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/frameparser"
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
+	"github.com/linjuya-lu/device-wiresink-go/internal/serialport"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
+)
+
+// 根据 WireSink.Transport 配置创建汇聚网关传输
+func (d *WireSinkDriver) newTransport() (transport.Transport, error) {
+	name := strings.ToLower(d.config().WireSink.Transport)
+	switch name {
+	case transport.NameMessageBus:
+		return d.newMessageBusTransport()
+	case transport.NameMQTT:
+		return d.newMQTTTransport()
+	case transport.NameMQTT5:
+		return d.newMQTT5Transport()
+	case transport.NameSerial:
+		return d.newSerialTransport()
+	case transport.NameTCP, transport.NameUDP:
+		return d.newSocketTransport(name)
+	case transport.NameLoopback:
+		return transport.NewLoopback(), nil
+	}
+	return nil, fmt.Errorf("不支持的传输方式 %q", name)
+}
+
+// 连接状态写到汇聚节点设备的 connectionState / pendingDownlinks 资源并上报
+func (d *WireSinkDriver) watchConnectionState(t transport.Transport) {
+	sn, ok := t.(transport.StateNotifier)
+	if !ok {
+		return
+	}
+	sn.OnStateChange(func(state string) {
+		dev := d.config().WireSink.SinkDeviceName
+		values := map[string]interface{}{
+			"connectionState":  state,
+			"pendingDownlinks": int32(sn.Pending()),
+		}
+		for name, v := range values {
+			config.Devices().Set(dev, name, v)
+		}
+		d.lc.Infof("%s 连接状态: %s，待补发下行 %d 条", t.Name(), state, sn.Pending())
+		// 回调在 MQTT 协程中执行，上报可能阻塞，放到单独协程
+		go d.AsyncReporting(dev, "connectionState", values)
+	})
+}
+
+// 独立 MQTT 连接，用于网关接在 EdgeX MessageBus 以外的 broker 上的场景
+func (d *WireSinkDriver) newMQTTTransport() (transport.Transport, error) {
+	cfg := d.config().WireSink
+	opts := mqttclient.Options{
+		BrokerURL:      cfg.BrokerURL,
+		ClientID:       brokerClientID(),
+		SkipCertVerify: cfg.BrokerSkipCertVerify,
+	}
+	if err := d.setBrokerAuth(&opts, cfg.BrokerAuthMode, cfg.BrokerSecretName); err != nil {
+		return nil, err
+	}
+	minDelay, _ := parsePositiveDuration(cfg.BrokerReconnectMin)
+	maxDelay, _ := parsePositiveDuration(cfg.BrokerReconnectMax)
+	client, err := mqttclient.NewManaged(opts, mqttclient.Backoff{Min: minDelay, Max: maxDelay})
+	if err != nil {
+		return nil, fmt.Errorf("初始化 MQTT 客户端失败: %w", err)
+	}
+	return transport.NewMQTT(client, transport.MQTTConfig{
+		UpTopic:    common.BuildTopic(common.DefaultBaseTopic, cfg.Writable.UpTopic),
+		DownTopic:  common.BuildTopic(common.DefaultBaseTopic, cfg.Writable.DownTopic),
+		SinkEid:    cfg.SinkEid,
+		OutboxSize: cfg.BrokerOutboxSize,
+		OutboxFile: cfg.BrokerOutboxFile,
+	}), nil
+}
+
+// MQTT v5 请求/响应模式，下行等待网关返回投递结果
+// 响应 topic 为 DownTopic/response/<ClientId>，各实例互不干扰
+func (d *WireSinkDriver) newMQTT5Transport() (transport.Transport, error) {
+	cfg := d.config().WireSink
+	opts := mqttclient.Options{
+		BrokerURL:      cfg.BrokerURL,
+		ClientID:       brokerClientID(),
+		SkipCertVerify: cfg.BrokerSkipCertVerify,
+	}
+	if err := d.setBrokerAuth(&opts, cfg.BrokerAuthMode, cfg.BrokerSecretName); err != nil {
+		return nil, err
+	}
+	minDelay, _ := parsePositiveDuration(cfg.BrokerReconnectMin)
+	maxDelay, _ := parsePositiveDuration(cfg.BrokerReconnectMax)
+	timeout, _ := parsePositiveDuration(cfg.BrokerResponseTimeout)
+	downTopic := common.BuildTopic(common.DefaultBaseTopic, cfg.Writable.DownTopic)
+	respTopic := common.BuildTopic(downTopic, "response", opts.ClientID)
+	client, err := mqttclient.NewV5(opts, mqttclient.Backoff{Min: minDelay, Max: maxDelay}, respTopic)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 MQTT v5 客户端失败: %w", err)
+	}
+	d.lc.Infof("MQTT v5 请求/响应模式，响应 topic: %s", respTopic)
+	return transport.NewMQTT5(client, transport.MQTT5Config{
+		UpTopic:         common.BuildTopic(common.DefaultBaseTopic, cfg.Writable.UpTopic),
+		DownTopic:       downTopic,
+		SinkEid:         cfg.SinkEid,
+		ResponseTimeout: timeout,
+	}), nil
+}
+
+// 直连 broker 的 ClientId，按主机名与进程号区分实例
+func brokerClientID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("wiresink-%s-%d", host, os.Getpid())
+}
+
+// 打开串口，下行使用 AT 指令
+func (d *WireSinkDriver) newSerialTransport() (transport.Transport, error) {
+	cfg := d.config().WireSink
+	port, err := serialport.Open(cfg.SerialPort, cfg.SerialBaudRate)
+	if err != nil {
+		return nil, fmt.Errorf("初始化串口失败: %w", err)
+	}
+	d.lc.Infof("已打开串口 %s (%d baud)，使用 AT 指令收发", cfg.SerialPort, cfg.SerialBaudRate)
+	return transport.NewSerial(port), nil
+}
+
+// 监听 TCP/UDP 端口，由网关主动连接转发帧
+func (d *WireSinkDriver) newSocketTransport(network string) (transport.Transport, error) {
+	cfg := d.config().WireSink
+	t, err := transport.NewSocket(network, cfg.SocketListen, cfg.SocketFraming)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 %s 传输失败: %w", network, err)
+	}
+	return t, nil
+}
+
+// 启动传输并接入解析协程
+func (d *WireSinkDriver) startTransport(t transport.Transport) error {
+	if err := t.Start(); err != nil {
+		return fmt.Errorf("启动 %s 传输失败: %w", t.Name(), err)
+	}
+	frameparser.StartParser(t.Frames(), d.AsyncReporting)
+	return nil
+}
+
+// topic 或凭据变化后重建传输
+// 同一 ClientId 不能同时在线，先关闭旧传输再建立新传输；
+// 旧传输关闭时其上行通道随之关闭，对应的解析协程自行退出。
+// 新传输建立失败时不再保留已关闭的旧传输，下行直接返回无可用传输，并按退避间隔重试
+func (d *WireSinkDriver) restartTransport() error {
+	d.transportMu.Lock()
+	defer d.transportMu.Unlock()
+	if d.transportStopped {
+		return nil
+	}
+	if d.transportRetry != nil {
+		d.transportRetry.Stop()
+		d.transportRetry = nil
+	}
+
+	if old := d.transport; old != nil {
+		if err := old.Close(); err != nil {
+			d.lc.Errorf("关闭 %s 传输失败: %v", old.Name(), err)
+		}
+	}
+	d.transport = nil
+	relay.SetTransport(nil)
+
+	t, err := d.newTransport()
+	if err == nil {
+		d.watchConnectionState(t)
+		if err = d.startTransport(t); err != nil {
+			t.Close()
+		}
+	}
+	if err != nil {
+		d.scheduleTransportRetryLocked()
+		return err
+	}
+	d.transport = t
+	d.transportFailures = 0
+	relay.SetTransport(t)
+	return nil
+}
+
+// 重建失败后按 BrokerReconnectMin/Max 退避重试，调用方持有 transportMu
+func (d *WireSinkDriver) scheduleTransportRetryLocked() {
+	cfg := d.config().WireSink
+	delay, _ := parsePositiveDuration(cfg.BrokerReconnectMin)
+	maxDelay, _ := parsePositiveDuration(cfg.BrokerReconnectMax)
+	for i := 0; i < d.transportFailures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	d.transportFailures++
+	d.lc.Warnf("传输不可用，%s 后第 %d 次重建", delay, d.transportFailures)
+	d.transportRetry = time.AfterFunc(delay, func() {
+		if err := d.restartTransport(); err != nil {
+			d.lc.Errorf("重建传输失败: %v", err)
+		}
+	})
+}
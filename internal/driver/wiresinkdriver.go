@@ -3,9 +3,7 @@ package driver
 import (
 	"encoding/hex"
 	"fmt"
-	"log"
 	"math"
-	"os"
 	"strconv"
 	"sync"
 	"time"
@@ -17,14 +15,47 @@ import (
 	"github.com/edgexfoundry/go-mod-core-contracts/v4/models"
 	"github.com/linjuya-lu/device-wiresink-go/internal/config"
 	"github.com/linjuya-lu/device-wiresink-go/internal/frameparser"
-	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+	"github.com/linjuya-lu/device-wiresink-go/internal/history"
+	"github.com/linjuya-lu/device-wiresink-go/internal/readbuf"
+	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
 )
 
 type WireSinkDriver struct {
 	lc      logger.LoggingClient
 	asyncCh chan<- *dsModels.AsyncValues
-	locker  sync.Mutex
 	sdk     interfaces.DeviceServiceSDK
+	// 服务自定义配置，Writable 部分运行时可能被替换
+	configMu      sync.RWMutex
+	serviceConfig *ServiceConfig
+	// 汇聚网关传输：messagebus / mqtt / serial / tcp / udp / loopback
+	transportMu sync.Mutex
+	transport   transport.Transport
+	// 重建失败后的重试定时器及连续失败次数，Stop 后不再重建
+	transportRetry    *time.Timer
+	transportFailures int
+	transportStopped  bool
+	// 设备在线检查
+	health *healthMonitor
+	// 运行时状态快照的定期保存
+	stateStop chan struct{}
+	stateDone chan struct{}
+	// 上报缓冲，SDK 管道阻塞时读数在此排队
+	readings     *readbuf.Buffer
+	readingsStop chan struct{}
+	readingsDone chan struct{}
+	// 资源值历史记录
+	historySub *config.Subscription
+	// 自动生成 Profile：设备名 → 已写入生成 Profile 的参量
+	profileGenMu    sync.Mutex
+	generatedParams map[string]map[string]bool
+	// 正在后台生成 Profile 的设备
+	profileGenRunning map[string]bool
+	// 生成失败的设备：上次尝试时间及当时的参量，参量不变时到期前不再重试
+	profileGenFailed map[string]profileGenFailure
+	// 上报过滤：设备名 → 资源 → 上次上报的值
+	reportMu     sync.Mutex
+	lastReported map[string]map[string]reportedValue
 }
 
 var once sync.Once
@@ -33,6 +64,10 @@ var driver *WireSinkDriver
 func WireSinkDeviceDriver() interfaces.ProtocolDriver {
 	once.Do(func() {
 		driver = new(WireSinkDriver)
+		driver.generatedParams = make(map[string]map[string]bool)
+		driver.profileGenRunning = make(map[string]bool)
+		driver.profileGenFailed = make(map[string]profileGenFailure)
+		driver.lastReported = make(map[string]map[string]reportedValue)
 	})
 	return driver
 }
@@ -41,33 +76,59 @@ func (d *WireSinkDriver) Initialize(sdk interfaces.DeviceServiceSDK) error {
 	d.sdk = sdk
 	d.lc = sdk.LoggingClient()
 	d.asyncCh = sdk.AsyncValuesChannel()
-	// -- 初始化 MQTT 客户端 -- //
-	brokerURL := "tcp://172.16.19.101:1883"
-	host, _ := os.Hostname()
-	clientID := fmt.Sprintf("wiresink-%s-%d", host, os.Getpid())
-
-	client, err := mqttclient.NewClient(brokerURL, clientID)
+	// -- 加载服务配置 -- //
+	if err := d.loadServiceConfig(); err != nil {
+		return err
+	}
+	// -- 初始化汇聚网关传输 -- //
+	t, err := d.newTransport()
 	if err != nil {
-		return fmt.Errorf("初始化 MQTT 客户端失败: %w", err)
+		return err
 	}
-	mqttclient.MqttClient = client
-	return nil
+	d.transport = t
+	relay.SetTransport(t)
+	d.watchConnectionState(t)
+	d.watchTransportSecret()
+	d.watchLifecycle()
+	// -- 下行跟踪、暂存下行、组命令及生命周期接口 -- //
+	if err := d.addDownlinkRoutes(); err != nil {
+		return err
+	}
+	if err := d.addStoreForwardRoutes(); err != nil {
+		return err
+	}
+	if err := d.addGroupRoutes(); err != nil {
+		return err
+	}
+	if err := d.addLifecycleRoutes(); err != nil {
+		return err
+	}
+	if err := d.addReadingBufferRoutes(); err != nil {
+		return err
+	}
+	return d.addHistoryRoutes()
 }
 
 func (d *WireSinkDriver) Start() error {
-
-	devicesYAML := "../cmd/res/devices/devices.yaml"
-	profilesDir := "../cmd/res/profiles"
-
-	if err := config.InitDeviceResources(devicesYAML, profilesDir); err != nil {
+	cfg := d.config().WireSink
+	if err := config.InitDeviceResources(cfg.DevicesFile, cfg.ProfilesDir); err != nil {
 		return fmt.Errorf("初始化设备资源失败: %w", err)
 	}
-	//订阅
-	if err := mqttclient.SubscribeSinkData(mqttclient.MqttClient, "edgex/service/request/device_wiresink/up", 0); err != nil {
-		log.Fatal(err)
+	// 恢复重启前的最近值、EID 映射等，读命令不必等传感器重新上报
+	d.restoreState()
+	// 记录上行帧解析出的值，供 /api/v3/history 查询
+	d.historySub = history.Watch(config.Devices())
+	// 解析出的读数经上报缓冲交给 SDK
+	if err := d.startReadingBuffer(); err != nil {
+		return err
+	}
+	// 建立连接/订阅，启动解析协程
+	d.transportMu.Lock()
+	err := d.startTransport(d.transport)
+	d.transportMu.Unlock()
+	if err != nil {
+		return err
 	}
-	// 解协程
-	frameparser.StartParser(mqttclient.SinkRawDataCh, d.AsyncReporting)
 
 	//分片解析
 	go func() {
@@ -76,25 +137,40 @@ func (d *WireSinkDriver) Start() error {
 		}
 	}()
 
-	//EID和设备名映射
-	config.UpdateSensorMapping()
-
-	startHealthCheckLoop() //状态控制
+	// 在线状态随数据更新推送，不再轮询；变化时同步设备 OperatingState
+	d.health = startHealthMonitor(d.syncOperatingState)
+	d.startStateSaver()
+	// 核对 Profile 单位与参量表、派生资源定义，不一致时告警
+	d.checkUnits()
 	d.lc.Infof("有线汇聚类边代已启动")
 	return nil
 }
 
+// 运行时值由设备存储自行加锁；同步命令需等待传感器响应，不再整体串行化，
+// 不同设备的命令可并发执行
 func (d *WireSinkDriver) HandleReadCommands(deviceName string, protocols map[string]models.ProtocolProperties, reqs []dsModels.CommandRequest) (res []*dsModels.CommandValue, err error) {
-	d.locker.Lock()
-	defer d.locker.Unlock()
 	d.lc.Infof("HandleReadCommands 调用: 设备=%s, 请求资源数=%d", deviceName, len(reqs))
 
-	values, ok := config.GetDeviceValues(deviceName)
+	// 缓存值超过 maxAge 时先向传感器查询
+	stale, err := d.refreshStale(deviceName, reqs)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := config.Devices().Values(deviceName)
 	if !ok {
 		return nil, fmt.Errorf("设备 %s 未找到或无可用值", deviceName)
 	}
 	for _, req := range reqs {
 		resName := req.DeviceResourceName
+		// 带 query 属性的资源：同步执行对应查询命令，返回传感器响应
+		if query, ok := req.Attributes[queryAttribute].(string); ok && query != "" {
+			cv, qerr := d.readQueryResponse(deviceName, resName, query)
+			if qerr != nil {
+				return nil, qerr
+			}
+			res = append(res, cv)
+			continue
+		}
 		// 如果是路由信息，取数据，序列化
 		if resName == "topologyDiagram" {
 			topo := config.GetTopoList() // []config.NodeTopology
@@ -113,13 +189,28 @@ func (d *WireSinkDriver) HandleReadCommands(deviceName string, protocols map[str
 		}
 		// 一般资源从 config 读取
 		val, exists := values[resName]
+		// 派生资源由原始状态参量的当前值解码
+		if v, ok := d.readDerived(deviceName, resName, values); ok {
+			val, exists = v, true
+		}
 		if !exists {
 			return nil, fmt.Errorf("设备 %s 上未找到资源 %s 的值", deviceName, resName)
 		}
+		// 解析值换算为工程量并检查范围
+		raw := val
+		val, quality, keep := d.convertChecked(deviceName, resName, val)
+		if !keep {
+			return nil, errOutOfRange(deviceName, resName, raw)
+		}
 		cv, err := makeCV(resName, req.Type, val)
 		if err != nil {
 			return nil, err
 		}
+		if age, ok := stale[resName]; ok {
+			markStale(cv, age)
+			d.lc.Warnf("设备 %s 资源 %s 未能刷新，返回缓存值", deviceName, resName)
+		}
+		markQuality(cv, quality)
 		d.lc.Infof("读取值: %s.%s = %v", deviceName, resName, val)
 		res = append(res, cv)
 	}
@@ -127,9 +218,6 @@ func (d *WireSinkDriver) HandleReadCommands(deviceName string, protocols map[str
 }
 
 func (d *WireSinkDriver) HandleWriteCommands(deviceName string, protocols map[string]models.ProtocolProperties, reqs []dsModels.CommandRequest, params []*dsModels.CommandValue) error {
-	d.locker.Lock()
-	defer d.locker.Unlock()
-
 	d.lc.Infof("HandleWriteCommands 调用: 设备=%s, 写入请求数=%d", deviceName, len(reqs))
 
 	if len(reqs) != len(params) {
@@ -139,54 +227,44 @@ func (d *WireSinkDriver) HandleWriteCommands(deviceName string, protocols map[st
 	for i, req := range reqs {
 		resName := req.DeviceResourceName
 		cv := params[i]
-		// 命令类型转换
-		v, _ := cv.Int8Value()
-		d.lc.Infof("Int8Value = %d", v)
-		// 如果是时间参数查询且值为 1
-		if resName == "Time_Parameter_Query" && v == 1 {
-			if err := d.handleTimeParameterQuery(deviceName); err != nil {
-				return err
-			}
-		}
-		// 如果是时间参数设置且值为 1
-		if resName == "Time_Parameter_Set" && v == 1 {
-			if err := d.handleTimeParameterSet(deviceName); err != nil {
-				return err
-			}
-		}
-		// 如果是复位命令且值为 1
-		if resName == "Reset_Set" && v == 1 {
-			if err := d.handleResetCommand(deviceName); err != nil {
-				return err
-			}
-		}
-		// 如果是ID查询命令且值为 1
-		if resName == "ID_Query" && v == 1 {
-			if err := d.handleIdQuery(deviceName); err != nil {
+		// 带换算属性的资源：工程量反向换算为解析单位后，以参数设置报文下发到传感器
+		if dr, ok := d.sdk.DeviceResource(deviceName, resName); ok {
+			conv, ok, err := conversionOf(dr)
+			if err != nil {
 				return err
 			}
-		}
-		// 如果是所有通用参数查询命令且值为 1
-		if resName == "General_Parameter_Query" && v == 1 {
-			if err := d.handleGeneParaQuery(deviceName); err != nil {
-				return err
+			if ok {
+				raw, err := conv.reverse(resName, cv.Value)
+				if err != nil {
+					return fmt.Errorf("资源 %s: %w", resName, err)
+				}
+				set, err := d.parameterSetCommand(resName, raw)
+				if err != nil {
+					return err
+				}
+				if _, err := set.call(deviceName, set.priority); err != nil {
+					return err
+				}
+				config.Devices().Set(deviceName, resName, raw)
+				d.lc.Infof("写入值: %s.%s = %v（换算前 %v）", deviceName, resName, raw, cv.Value)
+				continue
 			}
 		}
-		// 如果是所有告警数据查询命令且值为 1
-		if resName == "Alarm_Parameter_Query" && v == 1 {
-			if err := d.handleIdAlarmParaQuery(deviceName); err != nil {
-				return err
-			}
+		// 命令类型转换
+		v, _ := cv.Int8Value()
+		d.lc.Infof("Int8Value = %d", v)
+		// 值为 1 时执行对应命令；控制命令等待传感器响应，超时返回错误
+		if v != 1 {
+			continue
 		}
-		// 如果是所有检测参数查询命令且值为 1
-		if resName == "Monitoring_Data_Query" && v == 1 {
-			if err := d.handleIdMoniDataQuery(deviceName); err != nil {
+		if resName == "topologyDiagramQuery" {
+			if err := d.handleRouterParameterQuery(deviceName); err != nil {
 				return err
 			}
+			continue
 		}
-		// 如果是网络拓扑查询命令且值为 1
-		if resName == "topologyDiagramQuery" && v == 1 {
-			if err := d.handleRouterParameterQuery(deviceName); err != nil {
+		if cmd, ok := d.controlCommands()[resName]; ok {
+			if _, err := cmd.call(deviceName, cmd.priority); err != nil {
 				return err
 			}
 		}
@@ -196,8 +274,29 @@ func (d *WireSinkDriver) HandleWriteCommands(deviceName string, protocols map[st
 
 func (d *WireSinkDriver) Stop(force bool) error {
 	d.lc.Info("wireSinkDriver.Stop: device-wiresink driver is stopping...")
-	// 关闭通道
-	close(config.WriteChan)
+	if d.health != nil {
+		d.health.Close()
+	}
+	if d.historySub != nil {
+		d.historySub.Close()
+	}
+	// 保存最后一次快照
+	d.stopStateSaver()
+	d.stopReadingBuffer()
+	// 停止各汇聚节点的下行调度，排队中的下行返回失败
+	relay.CloseSchedulers()
+	// 关闭传输
+	d.transportMu.Lock()
+	defer d.transportMu.Unlock()
+	d.transportStopped = true
+	if d.transportRetry != nil {
+		d.transportRetry.Stop()
+	}
+	if d.transport != nil {
+		if err := d.transport.Close(); err != nil {
+			d.lc.Errorf("关闭 %s 传输失败: %v", d.transport.Name(), err)
+		}
+	}
 	return nil
 }
 
@@ -223,11 +322,11 @@ func (d *WireSinkDriver) AddDevice(deviceName string, protocols map[string]model
 		resName := dr.Name
 		defaultValue := dr.Properties.DefaultValue
 		valueType := dr.Properties.ValueType
-		if err := config.DeviceInit(deviceName, resName, defaultValue, valueType); err != nil {
-			return fmt.Errorf("初始化设备 %s 资源 %s 失败：%v", deviceName, resName, err)
-		}
+		config.Devices().InitDefault(deviceName, resName, defaultValue, valueType)
 		d.lc.Infof("已将设备 %s 的资源 %s 初始化为默认值: %s (类型: %s)", deviceName, resName, defaultValue, valueType)
 	}
+	d.checkProfileUnits(prof)
+	d.checkDerivedResources(prof)
 	return nil
 }
 
@@ -248,11 +347,11 @@ func (d *WireSinkDriver) UpdateDevice(deviceName string, protocols map[string]mo
 		resName := dr.Name
 		defaultValue := dr.Properties.DefaultValue
 		valueType := dr.Properties.ValueType
-		if err := config.DeviceInit(deviceName, resName, defaultValue, valueType); err != nil {
-			return fmt.Errorf("更新设备 %s 资源 %s 失败：%v", deviceName, resName, err)
-		}
+		config.Devices().InitDefault(deviceName, resName, defaultValue, valueType)
 		d.lc.Infof("已将设备 %s 的资源 %s 重新初始化为默认值: %s (类型: %s)", deviceName, resName, defaultValue, valueType)
 	}
+	d.checkProfileUnits(prof)
+	d.checkDerivedResources(prof)
 
 	d.lc.Infof("已刷新设备 %s 的资源值为最新默认配置", deviceName)
 	return nil
@@ -261,16 +360,14 @@ func (d *WireSinkDriver) UpdateDevice(deviceName string, protocols map[string]mo
 func (d *WireSinkDriver) RemoveDevice(deviceName string, protocols map[string]models.ProtocolProperties) error {
 	d.lc.Debugf("Device %s is removed", deviceName)
 
-	// 删除运行时值表
-	if err := config.DeleteDeviceValues(deviceName); err != nil {
+	// 删除运行时值及 EID 映射
+	if err := config.Devices().Delete(deviceName); err != nil {
 		d.lc.Errorf("删除设备 %s 的运行时值失败: %v", deviceName, err)
 		return fmt.Errorf("删除设备 %s 的运行时值失败: %w", deviceName, err)
 	}
-	// 删除 sensorID 到 deviceName 的所有映射
-	if err := config.DeleteSensorIDMappingsByDevice(deviceName); err != nil {
-		d.lc.Errorf("删除设备 %s 的传感器映射失败: %v", deviceName, err)
-		return fmt.Errorf("删除设备 %s 的传感器映射失败: %w", deviceName, err)
-	}
+	config.DeleteObservedParams(deviceName)
+	d.forgetGeneratedProfile(deviceName)
+	d.forgetReported(deviceName)
 	d.lc.Infof("已移除设备 %s 的所有运行时数据和映射", deviceName)
 	return nil
 }
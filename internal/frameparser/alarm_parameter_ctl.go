@@ -10,7 +10,7 @@ import "encoding/binary"
 func BuildAlarmParameterQueryFrame(sensorID [6]byte) ([]byte, error) {
 	const (
 		packetType     = 0x04 // 3bit = 100b
-		ctrlAlarmQuery = 0x03 // 7bit，协议中“告警参数查询”对应的 CtrlType（示例值，按文档替换）
+		ctrlAlarmQuery = 0x03 // 7bit，协议中“告警参数查询”对应的 CtrlType
 		dataLen        = 0x0F // 4bit = 1111b, 请求所有告警参数
 		fragInd        = 0    // 1bit
 		requestSetFlag = 0    // 1bit = 查询
@@ -0,0 +1,80 @@
+package frameparser
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// 重组中的分片缓存，用于落盘后恢复
+type Fragment struct {
+	Eid        string           `json:"eid"`
+	SSEQ       uint8            `json:"sseq"`
+	StartPSEQ  uint8            `json:"startPseq"`
+	Expected   uint8            `json:"expected"`
+	FinalPSEQ  uint8            `json:"finalPseq"`
+	Buffer     []byte           `json:"buffer"`
+	OutOfOrder map[uint8][]byte `json:"outOfOrder,omitempty"`
+	Started    time.Time        `json:"started"`
+}
+
+// 当前重组中的分片缓存副本，按 EID 排序
+func Fragments() []Fragment {
+	cacheMu.Lock()
+	out := make([]Fragment, 0, len(sduCaches))
+	for eid, c := range sduCaches {
+		f := Fragment{
+			Eid:        eid,
+			SSEQ:       c.SSEQ,
+			StartPSEQ:  c.startPSEQ,
+			Expected:   c.expected,
+			FinalPSEQ:  c.finalPSEQ,
+			Buffer:     append([]byte(nil), c.buffer...),
+			OutOfOrder: make(map[uint8][]byte, len(c.outOfOrder)),
+			Started:    c.started,
+		}
+		for k, v := range c.outOfOrder {
+			f.OutOfOrder[k] = append([]byte(nil), v...)
+		}
+		out = append(out, f)
+	}
+	cacheMu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Eid < out[j].Eid })
+	return out
+}
+
+// 恢复重启前的分片缓存，超过重组超时的丢弃，剩余时间到期后照常回失败ACK；返回恢复条数
+func RestoreFragments(frags []Fragment) int {
+	timeout := time.Duration(reassembleTimeout.Load())
+	n := 0
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	for _, f := range frags {
+		remaining := timeout - time.Since(f.Started)
+		if remaining <= 0 {
+			continue
+		}
+		if _, ok := sduCaches[f.Eid]; ok {
+			continue
+		}
+		c := &SDUCache{
+			SSEQ:       f.SSEQ,
+			expected:   f.Expected,
+			finalPSEQ:  f.FinalPSEQ,
+			buffer:     f.Buffer,
+			outOfOrder: f.OutOfOrder,
+			startPSEQ:  f.StartPSEQ,
+			started:    f.Started,
+		}
+		if c.outOfOrder == nil {
+			c.outOfOrder = make(map[uint8][]byte)
+		}
+		armTimeout(f.Eid, c, remaining)
+		sduCaches[f.Eid] = c
+		n++
+	}
+	if n > 0 {
+		log.Printf("ℹ 恢复 %d 个重组中的分片缓存", n)
+	}
+	return n
+}
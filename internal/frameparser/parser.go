@@ -10,9 +10,24 @@ import (
 	"time"
 
 	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/lifecycle"
 	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
+	"github.com/linjuya-lu/device-wiresink-go/internal/sfqueue"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
 )
 
+// 已校验通过的已知传感器上行（应答已排队之后）：
+// 记录传感器所经的汇聚节点，下行排入该节点的调度器；
+// 串口模式的 SinkEid 是 +DRX 中的对端地址，下行都经本地模块发出，归入默认汇聚节点。
+// 休眠传感器上线，随后下发暂存的下行
+func noteUplink(f transport.Frame, sensorID string) {
+	if f.Transport != transport.NameSerial {
+		relay.Route(sensorID, f.SinkEid)
+	}
+	sfqueue.Seen(sensorID)
+}
+
 // deviceName: 设备名称
 // sourceName: 上报的源名称
 // resourceNames: 已解析的资源名列表
@@ -25,11 +40,12 @@ type CallbackFunc func(deviceName, sourceName string, values map[string]interfac
 // 4. 按照参量个数逐个解析 ParamType(14bit)+LengthFlag(2bit) + 可选长度字段 + 数据
 // 5. 将数值按表转换为 float32/float64/int8等基本类型
 // 6. 针对 SensorID，调用 config.SetDeviceValue 存储解析结果
-func StartParser(frameCh <-chan []byte, cb CallbackFunc) {
+func StartParser(frameCh <-chan transport.Frame, cb CallbackFunc) {
 	// fmt.Printf("[StartParser] cb=%p\n", cb)
 
 	go func() {
-		for frame := range frameCh {
+		for f := range frameCh {
+			frame := f.Data
 			fmt.Printf("Received frame (%d bytes): % X\n", len(frame), frame)
 			// 最小长度校验：6字节ID +1字节头 +2字节CRC
 			if len(frame) < 9 {
@@ -42,9 +58,9 @@ func StartParser(frameCh <-chan []byte, cb CallbackFunc) {
 			// 读取6字节SensorID，使用Hex字符串表示
 			sidBytes := frame[0:6]
 			sensorID := strings.ToUpper(hex.EncodeToString(sidBytes))
-			deviceName, hasDevice := config.LookupDeviceName(sensorID)
+			deviceName, hasDevice := config.Devices().Lookup(sensorID)
 			if !hasDevice {
-				log.Printf("EID映射表 key: %#v", config.SensorIDToDeviceName)
+				log.Printf("EID映射表 key: %#v", config.Devices().EIDs())
 
 				log.Printf(">>[%s]<<", sensorID)
 
@@ -94,21 +110,24 @@ func StartParser(frameCh <-chan []byte, cb CallbackFunc) {
 					SendDataStatus(sensorID, 0b011, 0xFF, byte(dataCount))
 					// 告警报文
 				case 4, 5:
-					// 控制报文响应
-					handleFrameCtl(frame_ctl)
-					if config.ResourcesFlag {
-						cb(deviceName, "AsyncReporting", config.Resources1)
-						config.ResourcesFlag = false
+					noteUplink(f, sensorID)
+					// 控制报文响应，对应的控制下行标记为已响应
+					downlink.Responded(sensorID, packetTypeControl)
+					lifecycle.Fire(deviceName, lifecycle.EventResponse, "")
+					if values := handleFrameCtl(frame_ctl); len(values) > 0 {
+						cb(deviceName, "AsyncReporting", values)
 					}
 					continue
 				default:
 					// 其他不处理
+					noteUplink(f, sensorID)
 					continue
 				}
 			} else {
 				// 分片帧
 				ProcessFrame(frame_ctl)
 			}
+			noteUplink(f, sensorID)
 			idx := 7
 			parsed := 0
 			resourceValues := make(map[string]interface{})
@@ -150,7 +169,8 @@ func StartParser(frameCh <-chan []byte, cb CallbackFunc) {
 					} else {
 						// 写入运行时值表
 						if val != nil {
-							config.SetDeviceValue(deviceName, info.Name, val)
+							config.Devices().SetFrom(deviceName, info.Name, val, frame_ctl.Origin())
+							config.RecordObservedParam(deviceName, info)
 							resourceValues[info.Name] = val
 							log.Printf("✅ 写入值 %s.%s = %v %s", deviceName, info.Name, val, info.Unit)
 						}
@@ -187,9 +207,8 @@ func StartParser(frameCh <-chan []byte, cb CallbackFunc) {
 //   - Data_Status: 上传状态 0xFF 成功，0x00 失败
 //   - CRC16: 对整帧前 8 字节 CRC16 校验，高低字节附加
 func SendDataStatus(sensorKey string, packetType byte, dataStatus byte, dataLen byte) error {
-	var eidStr = "238A0841D828"
-	// 解码 EID
-	keyBytes, err := hex.DecodeString(eidStr)
+	// 解码 EID：按《Q/GDW 12184—2021》附录 D，应答帧内的 SensorID 为被应答的传感器
+	keyBytes, err := hex.DecodeString(sensorKey)
 	if err != nil {
 		return errors.New("invalid sensorKey hex: " + err.Error())
 	}
@@ -208,12 +227,12 @@ func SendDataStatus(sensorKey string, packetType byte, dataStatus byte, dataLen
 	crc := CRC16(packet)
 	packet = append(packet, byte(crc>>8), byte(crc&0xFF))
 	//发送
-	relay.SendFrame(sensorKey, packet)
-	return nil
+	// 应答须在传感器接收窗口内送达，优先下发
+	return relay.SendAck(sensorKey, packet)
 }
 
 func onDataReceived(deviceName string) {
 	// 写入时间戳（纳秒）
 	ts := time.Now().UnixNano()
-	config.SetDeviceValue(deviceName, "lastDataTimestamp", ts)
+	config.Devices().Set(deviceName, "lastDataTimestamp", ts)
 }
@@ -1,27 +1,48 @@
 package frameparser
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/ctlwait"
 )
 
-// 解析控制帧
-func handleFrameCtl(frameCtl config.Frame) {
+// 解析控制帧，结果按（SensorID, CtrlType）交给等待该响应的命令
+// 返回需要作为读数上报的参量值
+func handleFrameCtl(frameCtl config.Frame) map[string]interface{} {
 	raw := frameCtl.Payload // Payload 是字节流
 	if len(raw) < 1 {
 		log.Printf("[CTL] payload 长度不足，跳过")
-		return
+		return nil
 	}
 	// 高 7 位为 CtrlType，最低位为 RequestSetFlag
 	head := raw[0]
 	log.Printf("[CTL] head=0x%02X (%d)", head, head)
+	var report bool
+	resp := ctlwait.Response{
+		Eid:        frameCtl.SensorID,
+		CtrlType:   head >> 1,
+		RequestSet: head&0x01 == 1,
+	}
 	// 根据 head 查找解析函数
 	if handle, ok := config.LookupResponseHandle(head); ok {
-		if err := handle.Parse(raw[1:], frameCtl); err != nil {
+		values, err := handle.Parse(raw[1:], frameCtl)
+		if err != nil {
 			log.Printf("❌ 参数解析失败 head=0x%02X: %v", head, err)
+			resp.Err = fmt.Errorf("响应解析失败: %w", err)
 		}
+		resp.Values = values
+		report = handle.Report
 	} else {
 		log.Printf("未找到解析函数 head=0x%02X", head)
+		resp.Err = fmt.Errorf("不支持的控制响应 CtrlType=0x%02X", resp.CtrlType)
+	}
+	if ctlwait.Deliver(resp) {
+		log.Printf("[CTL] 响应已交给等待中的命令 EID=%s CtrlType=0x%02X", resp.Eid, resp.CtrlType)
+	}
+	if !report {
+		return nil
 	}
+	return resp.Values
 }
@@ -0,0 +1,71 @@
+package frameparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/relay"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
+)
+
+// 经回环传输注入一条监测报文：解析出的值交给回调，并回复数据上传应答
+func TestStartParserLoopback(t *testing.T) {
+	const device, eid = "loopback-sensor", "0102030405A6"
+	config.Devices().InitDefault(device, config.EIDResource, eid, "String")
+	defer config.Devices().Delete(device)
+
+	lb := transport.NewLoopback()
+	if err := lb.Start(); err != nil {
+		t.Fatal(err)
+	}
+	relay.SetTransport(lb)
+	defer relay.SetTransport(nil)
+
+	type report struct {
+		device string
+		values map[string]interface{}
+	}
+	reports := make(chan report, 1)
+	StartParser(lb.Frames(), func(deviceName, _ string, values map[string]interface{}) {
+		reports <- report{deviceName, values}
+	})
+	defer lb.Close()
+
+	// SensorID + 头(1 个参量，监测报文) + 温度(类型码 5，4 字节) + CRC
+	sid := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0xA6}
+	frame := append(append([]byte(nil), sid...), 0x10)
+	frame = binary.LittleEndian.AppendUint16(frame, 5<<2)
+	frame = binary.LittleEndian.AppendUint32(frame, math.Float32bits(23.5))
+	frame = binary.BigEndian.AppendUint16(frame, CRC16(frame))
+	if err := lb.Inject("SINK01", frame); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-reports:
+		if r.device != device || r.values["Temperature"] != float32(23.5) {
+			t.Fatalf("上报 = %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到解析结果")
+	}
+	if v, _ := config.Devices().Get(device, "Temperature"); v != float32(23.5) {
+		t.Fatalf("运行时值 = %v", v)
+	}
+
+	// 应答：SensorID + 头(参量个数 1，类型 001) + 成功 0xFF + CRC
+	want := append(append([]byte(nil), sid...), 0x11, 0xFF)
+	want = binary.BigEndian.AppendUint16(want, CRC16(want))
+	select {
+	case s := <-lb.Sent():
+		if s.Eid != eid || !bytes.Equal(s.Frame, want) {
+			t.Fatalf("应答 = %s % X，期望 %s % X", s.Eid, s.Frame, eid, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到数据上传应答")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/linjuya-lu/device-wiresink-go/internal/config"
@@ -20,14 +21,27 @@ type SDUCache struct {
 	buffer     []byte
 	outOfOrder map[uint8][]byte
 	timer      *time.Timer
+	startPSEQ  uint8
+	started    time.Time
 }
 
-const (
-	// 分片最大重传次数
-	// maxRetransmits = 3
-	// 重组超时，超过此时间未完成拼接则丢弃并回ACK失败
-	reassembleTimeout = 20 * time.Second
-)
+// 分片最大重传次数
+// maxRetransmits = 3
+
+// 重组超时，超过此时间未完成拼接则丢弃并回ACK失败
+// 默认 20s，可由服务配置在运行时修改
+var reassembleTimeout atomic.Int64
+
+func init() {
+	reassembleTimeout.Store(int64(20 * time.Second))
+}
+
+// 设置分片重组超时，对之后开始重组的 SDU 生效
+func SetReassembleTimeout(d time.Duration) {
+	if d > 0 {
+		reassembleTimeout.Store(int64(d))
+	}
+}
 
 var (
 	cacheMu sync.Mutex
@@ -72,15 +86,9 @@ func ProcessFrame(frame config.Frame) {
 	// 首片
 	if !exists {
 		if isStart(PSEQ) {
-			cache = &SDUCache{SSEQ: SSEQ, expected: PSEQ + 1, outOfOrder: make(map[uint8][]byte)}
+			cache = &SDUCache{SSEQ: SSEQ, expected: PSEQ + 1, outOfOrder: make(map[uint8][]byte), startPSEQ: PSEQ, started: time.Now()}
 			cache.buffer = append(cache.buffer, data...)
-			cache.timer = time.AfterFunc(reassembleTimeout, func() {
-				cacheMu.Lock()
-				delete(sduCaches, sensorKey)
-				cacheMu.Unlock()
-				// 超时丢弃后发失败ACK
-				sendAck(sensorKey, SSEQ, false, PSEQ)
-			})
+			armTimeout(sensorKey, cache, time.Duration(reassembleTimeout.Load()))
 			sduCaches[sensorKey] = cache
 			// 对每片都应答ACK成功
 			sendAck(sensorKey, SSEQ, true, PSEQ)
@@ -146,6 +154,19 @@ func ProcessFrame(frame config.Frame) {
 	cacheMu.Unlock()
 }
 
+// 重组超时后丢弃缓存并回失败ACK
+func armTimeout(sensorKey string, cache *SDUCache, d time.Duration) {
+	cache.timer = time.AfterFunc(d, func() {
+		cacheMu.Lock()
+		if sduCaches[sensorKey] == cache {
+			delete(sduCaches, sensorKey)
+		}
+		cacheMu.Unlock()
+		// 超时丢弃后发失败ACK
+		sendAck(sensorKey, cache.SSEQ, false, cache.startPSEQ)
+	})
+}
+
 // 停止定时器并输出完整SDU
 func finalize(sensorKey string, frame config.Frame) {
 	cache := sduCaches[sensorKey]
@@ -177,7 +198,8 @@ func sendAck(sensorKey string, sseq uint8, ackOK bool, pseq uint8) {
 		Check:      CRC16(ackData),
 	}
 	data := ackFrame.Bytes()
-	relay.SendFrame(sensorKey, data)
+	// 应答须在传感器接收窗口内送达，优先下发
+	relay.SendAck(sensorKey, data)
 }
 
 // isStart/PSEQ 首尾判断
@@ -221,7 +243,7 @@ func ShardingParser(frameCh <-chan config.Frame) error {
 			// 提取原始值字节
 			valBytes := frame.Payload[idx : idx+int(dataLen)]
 			idx += int(dataLen)
-			deviceName, hasDevice := config.LookupDeviceName(frame.SensorID)
+			deviceName, hasDevice := config.Devices().Lookup(frame.SensorID)
 			if !hasDevice {
 				log.Printf("未知 SensorID=%s，跳过本帧", frame.SensorID)
 				continue
@@ -233,7 +255,8 @@ func ShardingParser(frameCh <-chan config.Frame) error {
 					log.Printf("❌ 参数 %s.%s 解析失败: %v", deviceName, info.Name, err)
 				} else {
 					// 写入运行时值表
-					config.SetDeviceValue(deviceName, info.Name, val)
+					config.Devices().SetFrom(deviceName, info.Name, val, frame.Origin())
+					config.RecordObservedParam(deviceName, info)
 					log.Printf("✅ 写入值 %s.%s = %v %s", deviceName, info.Name, val, info.Unit)
 				}
 			} else {
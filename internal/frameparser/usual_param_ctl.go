@@ -9,8 +9,8 @@ import (
 )
 
 const (
-	// CtrlType: 通用参数查询/设置 (7bit)，协议附录B 定义
-	ctrlTypeGeneralParams = 0x03
+	// CtrlType: 通用参数查询/设置 (7bit)，协议附录B 定义，与 BuildParameterQueryFrame 一致（0x03 为告警参数）
+	ctrlTypeGeneralParams = 0x01
 	// 最大支持一次下发/查询的参数数量
 	maxParams = 16
 )
@@ -0,0 +1,287 @@
+package history
+
+// 资源值历史：每个设备资源保留最近若干条由上行帧解析出的值及其来源帧，
+// 供调试时查询一段时间内传感器上报了什么（core-data 未启用时也可用）
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+)
+
+// 未配置时每个资源保留的条数
+const defaultSize = 1000
+
+// 非有限浮点值的质量标记，JSON 无法表示这些值，Value 记为 null
+const (
+	QualityNaN    = "NaN"
+	QualityPosInf = "+Inf"
+	QualityNegInf = "-Inf"
+)
+
+// 一条历史值，Value 为 NaN、±Inf 时记为 null 并在 Quality 中注明
+type Sample struct {
+	Time    time.Time      `json:"time"`
+	Value   interface{}    `json:"value"`
+	Quality string         `json:"quality,omitempty"`
+	Origin  *config.Origin `json:"origin,omitempty"`
+}
+
+// 降采样后的一个时间段：数值取平均及最小、最大值，非数值取段内最后一个
+type Bucket struct {
+	Start   time.Time      `json:"start"`
+	Count   int            `json:"count"`
+	Value   interface{}    `json:"value"`
+	Min     *float64       `json:"min,omitempty"`
+	Max     *float64       `json:"max,omitempty"`
+	Quality string         `json:"quality,omitempty"` // 段内最后一条的质量标记
+	Origin  *config.Origin `json:"origin,omitempty"`  // 段内最后一条的来源帧
+}
+
+// 定长环形缓冲，满时覆盖最旧的
+type ring struct {
+	items []Sample
+	next  int
+	full  bool
+}
+
+func (r *ring) add(s Sample) {
+	r.items[r.next] = s
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// 按时间先后返回全部
+func (r *ring) all() []Sample {
+	if !r.full {
+		return append([]Sample(nil), r.items[:r.next]...)
+	}
+	out := make([]Sample, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	return append(out, r.items[:r.next]...)
+}
+
+// 调整容量，保留最新的
+func (r *ring) resize(n int) {
+	all := r.all()
+	if len(all) > n {
+		all = all[len(all)-n:]
+	}
+	r.items = make([]Sample, n)
+	copy(r.items, all)
+	r.next = len(all) % n
+	r.full = len(all) == n
+}
+
+var (
+	mu    sync.Mutex
+	size  = defaultSize
+	rings = make(map[string]map[string]*ring) // 设备 → 资源 → 历史
+)
+
+// 设置每个资源保留的条数，0 表示不记录历史，运行时修改即生效
+func Configure(n int) {
+	if n < 0 {
+		n = defaultSize
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n == size {
+		return
+	}
+	size = n
+	if n == 0 {
+		// 关闭历史记录，清除已有的
+		rings = make(map[string]map[string]*ring)
+		return
+	}
+	for _, res := range rings {
+		for _, r := range res {
+			r.resize(n)
+		}
+	}
+}
+
+// 记录一条值
+func Record(device, resource string, value interface{}, t time.Time, origin *config.Origin) {
+	mu.Lock()
+	defer mu.Unlock()
+	if size == 0 {
+		return
+	}
+	res, ok := rings[device]
+	if !ok {
+		res = make(map[string]*ring)
+		rings[device] = res
+	}
+	r, ok := res[resource]
+	if !ok {
+		r = &ring{items: make([]Sample, size)}
+		res[resource] = r
+	}
+	s := Sample{Time: t, Value: value, Origin: origin}
+	if q := nonFinite(value); q != "" {
+		s.Value, s.Quality = nil, q
+	}
+	r.add(s)
+}
+
+// NaN、±Inf 返回对应的质量标记，其余返回空
+func nonFinite(v interface{}) string {
+	var f float64
+	switch x := v.(type) {
+	case float32:
+		f = float64(x)
+	case float64:
+		f = x
+	default:
+		return ""
+	}
+	switch {
+	case math.IsNaN(f):
+		return QualityNaN
+	case math.IsInf(f, 1):
+		return QualityPosInf
+	case math.IsInf(f, -1):
+		return QualityNegInf
+	}
+	return ""
+}
+
+// 同步订阅设备存储，每条带来源帧的值都在写入时记录，不因消费过慢丢失；
+// 设备删除时清除其历史。返回的订阅 Close 后停止记录
+func Watch(store *config.DeviceStore) *config.Subscription {
+	return store.SubscribeFunc("", "", func(c config.Change) {
+		switch {
+		case c.Removed:
+			Remove(c.Device)
+		case c.Origin != nil:
+			Record(c.Device, c.Resource, c.Value, c.Time, c.Origin)
+		}
+	})
+}
+
+// 清除设备的历史
+func Remove(device string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(rings, device)
+}
+
+// 有历史的资源名，按名称排序
+func Resources(device string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(rings[device]))
+	for name := range rings[device] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// [start, end) 内的历史，零值表示不限，按时间先后；limit>0 时只保留最新的 limit 条
+func Query(device, resource string, start, end time.Time, limit int) []Sample {
+	mu.Lock()
+	r, ok := rings[device][resource]
+	var all []Sample
+	if ok {
+		all = r.all()
+	}
+	mu.Unlock()
+	out := make([]Sample, 0, len(all))
+	for _, s := range all {
+		if !start.IsZero() && s.Time.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !s.Time.Before(end) {
+			continue
+		}
+		out = append(out, s)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// 按 step 对齐分段降采样，samples 须按时间先后排列
+func Downsample(samples []Sample, step time.Duration) []Bucket {
+	var out []Bucket
+	var sum float64
+	var numeric int
+	for _, s := range samples {
+		start := s.Time.Truncate(step)
+		if len(out) == 0 || !out[len(out)-1].Start.Equal(start) {
+			closeBucket(out, sum, numeric)
+			out = append(out, Bucket{Start: start})
+			sum, numeric = 0, 0
+		}
+		b := &out[len(out)-1]
+		b.Count++
+		b.Origin = s.Origin
+		b.Value, b.Quality = s.Value, s.Quality
+		if f, ok := toFloat(s.Value); ok {
+			sum += f
+			numeric++
+			if b.Min == nil || f < *b.Min {
+				lo := f
+				b.Min = &lo
+			}
+			if b.Max == nil || f > *b.Max {
+				hi := f
+				b.Max = &hi
+			}
+		}
+	}
+	closeBucket(out, sum, numeric)
+	return out
+}
+
+// 段内全为数值时以平均值作为 Value
+func closeBucket(out []Bucket, sum float64, numeric int) {
+	if len(out) == 0 {
+		return
+	}
+	b := &out[len(out)-1]
+	if numeric > 0 && numeric == b.Count {
+		b.Value = sum / float64(numeric)
+	} else {
+		b.Min, b.Max = nil, nil
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	var f float64
+	switch x := v.(type) {
+	case float32:
+		f = float64(x)
+	case float64:
+		f = x
+	case int:
+		f = float64(x)
+	case int8:
+		f = float64(x)
+	case int16:
+		f = float64(x)
+	case int32:
+		f = float64(x)
+	case int64:
+		f = float64(x)
+	case uint8:
+		f = float64(x)
+	case uint16:
+		f = float64(x)
+	case uint32:
+		f = float64(x)
+	case uint64:
+		f = float64(x)
+	default:
+		return 0, false
+	}
+	return f, !math.IsNaN(f) && !math.IsInf(f, 0)
+}
@@ -0,0 +1,102 @@
+package history
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func samples(t0 time.Time, values ...interface{}) []Sample {
+	out := make([]Sample, len(values))
+	for i, v := range values {
+		out[i] = Sample{Time: t0.Add(time.Duration(i) * 10 * time.Second), Value: v}
+	}
+	return out
+}
+
+func TestDownsample(t *testing.T) {
+	t0 := time.Unix(1_700_000_040, 0) // 整分
+	got := Downsample(samples(t0, float32(1), int32(3), 2.0, 10.0, "off", 4.0, 6.0), time.Minute)
+	// 0s、10s … 50s 落在第一段，60s 落在第二段
+	if len(got) != 2 {
+		t.Fatalf("分段 = %+v", got)
+	}
+	b := got[0]
+	if b.Count != 6 || b.Value != 4.0 || b.Min != nil || b.Max != nil {
+		t.Fatalf("含非数值的段应取最后一个值且无 min/max: %+v", b)
+	}
+
+	got = Downsample(samples(t0, float32(1), int32(3), 2.0), time.Minute)
+	b = got[0]
+	if len(got) != 1 || b.Count != 3 || b.Value != 2.0 || *b.Min != 1 || *b.Max != 3 {
+		t.Fatalf("数值段 = %+v", b)
+	}
+	if !b.Start.Equal(t0) {
+		t.Fatalf("段起点 = %s，期望 %s", b.Start, t0)
+	}
+	if Downsample(nil, time.Minute) != nil {
+		t.Fatal("空输入应返回空")
+	}
+}
+
+func TestRingResize(t *testing.T) {
+	r := &ring{items: make([]Sample, 3)}
+	for i := 1; i <= 5; i++ {
+		r.add(Sample{Value: i})
+	}
+	check := func(want ...int) {
+		t.Helper()
+		all := r.all()
+		if len(all) != len(want) {
+			t.Fatalf("all = %v，期望 %v", all, want)
+		}
+		for i, w := range want {
+			if all[i].Value != w {
+				t.Fatalf("all = %v，期望 %v", all, want)
+			}
+		}
+	}
+	check(3, 4, 5)
+
+	// 缩小保留最新的
+	r.resize(2)
+	check(4, 5)
+	r.add(Sample{Value: 6})
+	check(5, 6)
+
+	// 扩大后继续追加，不覆盖
+	r.resize(4)
+	check(5, 6)
+	r.add(Sample{Value: 7})
+	r.add(Sample{Value: 8})
+	check(5, 6, 7, 8)
+	r.add(Sample{Value: 9})
+	check(6, 7, 8, 9)
+}
+
+// NaN、±Inf 记为 null 并标记质量，整个响应可以序列化
+func TestRecordNonFinite(t *testing.T) {
+	Configure(10)
+	defer Remove("dev")
+	now := time.Now()
+	Record("dev", "t", math.NaN(), now, nil)
+	Record("dev", "t", float32(math.Inf(1)), now.Add(time.Second), nil)
+	Record("dev", "t", math.Inf(-1), now.Add(2*time.Second), nil)
+	Record("dev", "t", 1.5, now.Add(3*time.Second), nil)
+
+	got := Query("dev", "t", time.Time{}, time.Time{}, 0)
+	want := []string{QualityNaN, QualityPosInf, QualityNegInf, ""}
+	for i, s := range got {
+		if s.Quality != want[i] || (want[i] != "" && s.Value != nil) {
+			t.Fatalf("第 %d 条 = %+v，期望 quality=%q", i, s, want[i])
+		}
+	}
+	if _, err := json.Marshal(got); err != nil {
+		t.Fatal(err)
+	}
+	buckets := Downsample(got, time.Hour)
+	if _, err := json.Marshal(buckets); err != nil {
+		t.Fatal(err)
+	}
+}
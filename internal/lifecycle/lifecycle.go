@@ -0,0 +1,202 @@
+package lifecycle
+
+// 传感器生命周期状态机：按设备跟踪 unknown / onboarding / online / sleeping / late /
+// offline / resetting / reconfiguring，由上行、控制响应、复位与重配命令以及在线检查的超时驱动
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateUnknown       State = "unknown"
+	StateOnboarding    State = "onboarding"
+	StateOnline        State = "online"
+	StateSleeping      State = "sleeping"
+	StateLate          State = "late"
+	StateOffline       State = "offline"
+	StateResetting     State = "resetting"
+	StateReconfiguring State = "reconfiguring"
+)
+
+type Event string
+
+const (
+	EventUplink      Event = "uplink"      // 收到上行数据
+	EventResponse    Event = "response"    // 收到控制响应
+	EventReset       Event = "reset"       // 下发复位
+	EventReconfigure Event = "reconfigure" // 下发参数设置
+	EventSleep       Event = "sleep"       // 休眠传感器接收窗口结束
+	EventLate        Event = "late"        // 超过期望周期未收到数据
+	EventTimeout     Event = "timeout"     // 超过 2 个周期未收到数据
+)
+
+// 每台设备保留的转换记录条数
+const historySize = 32
+
+// 状态转换表，未列出的事件不改变状态
+var transitions = map[State]map[Event]State{
+	StateUnknown: {
+		EventUplink:      StateOnboarding,
+		EventResponse:    StateOnboarding,
+		EventReset:       StateResetting,
+		EventReconfigure: StateReconfiguring,
+		EventTimeout:     StateOffline,
+	},
+	StateOnboarding: {
+		EventUplink:      StateOnline,
+		EventResponse:    StateOnline,
+		EventSleep:       StateSleeping,
+		EventLate:        StateLate,
+		EventTimeout:     StateOffline,
+		EventReset:       StateResetting,
+		EventReconfigure: StateReconfiguring,
+	},
+	StateOnline: {
+		EventSleep:       StateSleeping,
+		EventLate:        StateLate,
+		EventTimeout:     StateOffline,
+		EventReset:       StateResetting,
+		EventReconfigure: StateReconfiguring,
+	},
+	StateSleeping: {
+		EventUplink:      StateOnline,
+		EventResponse:    StateOnline,
+		EventLate:        StateLate,
+		EventTimeout:     StateOffline,
+		EventReset:       StateResetting,
+		EventReconfigure: StateReconfiguring,
+	},
+	StateLate: {
+		EventUplink:      StateOnline,
+		EventResponse:    StateOnline,
+		EventTimeout:     StateOffline,
+		EventReset:       StateResetting,
+		EventReconfigure: StateReconfiguring,
+	},
+	StateOffline: {
+		EventUplink:      StateOnline,
+		EventResponse:    StateOnline,
+		EventReset:       StateResetting,
+		EventReconfigure: StateReconfiguring,
+	},
+	// 复位后传感器重新入网
+	StateResetting: {
+		EventUplink:  StateOnboarding,
+		EventTimeout: StateOffline,
+	},
+	// 等待设置命令的响应
+	StateReconfiguring: {
+		EventResponse: StateOnline,
+		EventReset:    StateResetting,
+		EventTimeout:  StateOffline,
+	},
+}
+
+// 一次状态转换
+type Transition struct {
+	From   State     `json:"from"`
+	To     State     `json:"to"`
+	Event  Event     `json:"event"`
+	Time   time.Time `json:"time"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// 设备当前状态
+type Info struct {
+	Device string    `json:"device"`
+	State  State     `json:"state"`
+	Since  time.Time `json:"since"`
+}
+
+type machine struct {
+	state   State
+	since   time.Time
+	history []Transition
+}
+
+var (
+	mu       sync.Mutex
+	machines = make(map[string]*machine)
+	hook     func(device string, t Transition)
+)
+
+// 设置状态转换回调，在状态变化后调用
+func OnTransition(fn func(device string, t Transition)) {
+	mu.Lock()
+	defer mu.Unlock()
+	hook = fn
+}
+
+// 对设备施加事件，状态变化时返回转换记录
+func Fire(device string, ev Event, detail string) (Transition, bool) {
+	now := time.Now()
+	mu.Lock()
+	m, ok := machines[device]
+	if !ok {
+		m = &machine{state: StateUnknown, since: now}
+		machines[device] = m
+	}
+	to, ok := transitions[m.state][ev]
+	if !ok || to == m.state {
+		mu.Unlock()
+		return Transition{}, false
+	}
+	t := Transition{From: m.state, To: to, Event: ev, Time: now, Detail: detail}
+	m.state, m.since = to, now
+	m.history = append(m.history, t)
+	if len(m.history) > historySize {
+		m.history = m.history[len(m.history)-historySize:]
+	}
+	fn := hook
+	mu.Unlock()
+
+	log.Printf("ℹ 设备 %s 状态 %s → %s (%s)", device, t.From, t.To, ev)
+	if fn != nil {
+		fn(device, t)
+	}
+	return t, true
+}
+
+// 设备当前状态，未跟踪的设备为 unknown
+func Current(device string) Info {
+	mu.Lock()
+	defer mu.Unlock()
+	if m, ok := machines[device]; ok {
+		return Info{Device: device, State: m.state, Since: m.since}
+	}
+	return Info{Device: device, State: StateUnknown}
+}
+
+// 设备的转换记录，旧的在前
+func History(device string) []Transition {
+	mu.Lock()
+	defer mu.Unlock()
+	m, ok := machines[device]
+	if !ok {
+		return []Transition{}
+	}
+	return append([]Transition(nil), m.history...)
+}
+
+// 全部已跟踪设备的当前状态，按设备名排序
+func All() []Info {
+	mu.Lock()
+	out := make([]Info, 0, len(machines))
+	for name, m := range machines {
+		out = append(out, Info{Device: name, State: m.state, Since: m.since})
+	}
+	mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Device < out[j].Device })
+	return out
+}
+
+// 设备删除时清除状态
+func Remove(device string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(machines, device)
+}
@@ -0,0 +1,253 @@
+package mqttclient
+
+// 带生命周期管理的 MQTT 连接：
+// 自行按指数退避建立/恢复连接，连上后重新订阅所有 topic，并通知连接状态变化
+// clean session 下 broker 不保留订阅，重连后必须由客户端重新订阅
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// 连接状态
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+)
+
+// 未连接时发布
+var ErrNotConnected = errors.New("MQTT 未连接")
+
+// 单次连接等待时间
+const connectTimeout = 10 * time.Second
+
+// 重连退避：从 Min 开始每次翻倍，最大 Max
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// 第 n 次失败后的等待时间（n 从 0 开始），附加 ±20% 抖动避免多个实例同时重连
+func (b Backoff) delay(n int) time.Duration {
+	d := b.Min
+	for i := 0; i < n && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5+1)) - d/10
+	return d + jitter
+}
+
+type subscription struct {
+	qos     byte
+	handler mqtt.MessageHandler
+}
+
+// 受管理的 MQTT 连接
+type Managed struct {
+	client  mqtt.Client
+	backoff Backoff
+
+	mu       sync.Mutex
+	subs     map[string]subscription
+	state    string
+	onState  []func(state string)
+	closed   bool
+	lostCh   chan struct{}
+	done     chan struct{}
+	startOne sync.Once
+}
+
+// 创建连接但不立即连接，调用 Start 后开始连接
+func NewManaged(o Options, b Backoff) (*Managed, error) {
+	if b.Min <= 0 {
+		b.Min = time.Second
+	}
+	if b.Max < b.Min {
+		b.Max = b.Min
+	}
+	m := &Managed{
+		backoff: b,
+		subs:    make(map[string]subscription),
+		state:   StateDisconnected,
+		lostCh:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	opts, err := o.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts.SetAutoReconnect(false).
+		SetConnectRetry(false).
+		SetCleanSession(true).
+		SetOnConnectHandler(func(mqtt.Client) { m.onConnect() }).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) { m.onConnectionLost(err) })
+	m.client = mqtt.NewClient(opts)
+	return m, nil
+}
+
+// 注册连接状态回调，回调在 MQTT 协程中执行，不应阻塞
+func (m *Managed) OnStateChange(fn func(state string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onState = append(m.onState, fn)
+}
+
+// 启动连接维护协程
+func (m *Managed) Start() {
+	m.startOne.Do(func() { go m.run() })
+}
+
+// 连接、断线后按退避重连，直到 Close
+func (m *Managed) run() {
+	failures := 0
+	for {
+		// 重试期间保持 connecting，避免每次重试都产生状态变化
+		if failures == 0 {
+			m.setState(StateConnecting)
+		}
+		tok := m.client.Connect()
+		ok := tok.WaitTimeout(connectTimeout)
+		if ok && tok.Error() == nil {
+			failures = 0
+			select {
+			case <-m.lostCh:
+				continue
+			case <-m.done:
+				return
+			}
+		}
+		err := tok.Error()
+		if !ok {
+			err = errors.New("连接超时")
+		}
+		wait := m.backoff.delay(failures)
+		failures++
+		log.Printf("❌ MQTT 连接失败: %v，%s 后重试", err, wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// 连上后重新订阅全部 topic
+func (m *Managed) onConnect() {
+	m.mu.Lock()
+	subs := make(map[string]subscription, len(m.subs))
+	for t, s := range m.subs {
+		subs[t] = s
+	}
+	m.mu.Unlock()
+
+	for topic, s := range subs {
+		tok := m.client.Subscribe(topic, s.qos, s.handler)
+		if tok.WaitTimeout(connectTimeout) && tok.Error() == nil {
+			log.Printf("🔔 订阅数据: %s", topic)
+			continue
+		}
+		log.Printf("❌ 重新订阅 %s 失败: %v，断开后重连", topic, tok.Error())
+		// 订阅失败时主动断开，交由重连流程再次订阅
+		go m.client.Disconnect(0)
+		m.onConnectionLost(errors.New("订阅失败"))
+		return
+	}
+	m.setState(StateConnected)
+}
+
+func (m *Managed) onConnectionLost(err error) {
+	log.Printf("⚠ MQTT 连接断开: %v", err)
+	m.setState(StateDisconnected)
+	select {
+	case m.lostCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Managed) setState(state string) {
+	m.mu.Lock()
+	if m.closed || m.state == state {
+		m.mu.Unlock()
+		return
+	}
+	m.state = state
+	cbs := append([]func(string){}, m.onState...)
+	m.mu.Unlock()
+	for _, fn := range cbs {
+		fn(state)
+	}
+}
+
+// 当前连接状态
+func (m *Managed) State() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+func (m *Managed) Connected() bool {
+	return m.State() == StateConnected
+}
+
+// 登记订阅，已连接时立即订阅，之后每次重连自动重新订阅
+func (m *Managed) Subscribe(topic string, qos byte, handler mqtt.MessageHandler) error {
+	m.mu.Lock()
+	m.subs[topic] = subscription{qos: qos, handler: handler}
+	connected := m.state == StateConnected
+	m.mu.Unlock()
+	if !connected {
+		return nil
+	}
+	tok := m.client.Subscribe(topic, qos, handler)
+	tok.Wait()
+	return tok.Error()
+}
+
+// 取消订阅并不再在重连后恢复
+func (m *Managed) Unsubscribe(topic string) error {
+	m.mu.Lock()
+	delete(m.subs, topic)
+	connected := m.state == StateConnected
+	m.mu.Unlock()
+	if !connected {
+		return nil
+	}
+	tok := m.client.Unsubscribe(topic)
+	if !tok.WaitTimeout(time.Second) {
+		return errors.New("取消订阅超时")
+	}
+	return tok.Error()
+}
+
+// 已连接时发布，未连接返回 ErrNotConnected
+func (m *Managed) Publish(topic string, qos byte, payload []byte) error {
+	if !m.Connected() {
+		return ErrNotConnected
+	}
+	tok := m.client.Publish(topic, qos, false, payload)
+	tok.Wait()
+	return tok.Error()
+}
+
+// 停止重连并断开
+func (m *Managed) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	close(m.done)
+	m.mu.Unlock()
+	if m.client.IsConnectionOpen() {
+		m.client.Disconnect(250)
+	}
+}
@@ -1,6 +1,9 @@
 package mqttclient
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -10,30 +13,71 @@ import (
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/go-mod-messaging/v4/pkg/types"
+	"github.com/google/uuid"
 )
 
-var MqttClient mqtt.Client
+// 连接参数；证书均为 PEM 内容，通常来自 SecretStore
+type Options struct {
+	BrokerURL string // tcp://、ssl://、tls://、mqtts:// 等
+	ClientID  string
+	// 用户名/密码认证
+	Username string
+	Password string
+	// 服务端 CA、客户端证书与私钥（mTLS）
+	CACert     []byte
+	ClientCert []byte
+	ClientKey  []byte
+	// 跳过服务端证书校验，仅用于调试
+	SkipCertVerify bool
+}
 
-// 根据broker URL 和 clientID 创建并连接 MQTT 客户端
-func NewClient(brokerURL, clientID string) (mqtt.Client, error) {
+// 按 Options 生成 paho 连接参数（心跳、认证、TLS）
+func (o Options) clientOptions() (*mqtt.ClientOptions, error) {
 	opts := mqtt.NewClientOptions().
-		AddBroker(brokerURL).
-		SetClientID(clientID).
-		// 设置自动重连，心跳，超时等
-		SetConnectRetry(true).
-		SetConnectRetryInterval(5 * time.Second).
+		AddBroker(o.BrokerURL).
+		SetClientID(o.ClientID).
 		SetKeepAlive(60 * time.Second).
-		SetPingTimeout(10 * time.Second)
+		SetPingTimeout(10 * time.Second).
+		SetConnectTimeout(connectTimeout)
+	if o.Username != "" {
+		opts.SetUsername(o.Username)
+		opts.SetPassword(o.Password)
+	}
+	tlsCfg, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		opts.SetTLSConfig(tlsCfg)
+	}
+	return opts, nil
+}
 
-	client := mqtt.NewClient(opts)
-	token := client.Connect()
-	if ok := token.WaitTimeout(10 * time.Second); !ok {
-		return nil, fmt.Errorf("MQTT 连接超时")
+// 未配置任何证书且不跳过校验时返回 nil，使用 paho 默认 TLS 设置
+func (o Options) tlsConfig() (*tls.Config, error) {
+	if len(o.CACert) == 0 && len(o.ClientCert) == 0 && !o.SkipCertVerify {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.SkipCertVerify,
 	}
-	if err := token.Error(); err != nil {
-		return nil, fmt.Errorf("MQTT 连接失败: %w", err)
+	if len(o.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(o.CACert) {
+			return nil, errors.New("CA 证书解析失败")
+		}
+		cfg.RootCAs = pool
 	}
-	return client, nil
+	if len(o.ClientCert) > 0 || len(o.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("客户端证书/私钥解析失败: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
 }
 
 // EdgeX MessageBus 的通用消息格式
@@ -56,17 +100,13 @@ type SinkPayload struct {
 	Data      string `json:"Data"`      // 原始数据
 }
 
-// 订阅指定 topic，解析后把 Data 放入 SinkHexDataCh
-func SubscribeSinkData(cli mqtt.Client, topic string, qos byte) error {
-	log.Printf("🔔 订阅数据: %s", topic)
-	tok := cli.Subscribe(topic, qos, sinkMsgHandler)
-	tok.Wait()
-	return tok.Error()
+// 生成订阅回调：把消息原文交给 handler，由调用方解析信封
+func PayloadHandler(handler func(payload []byte)) mqtt.MessageHandler {
+	return func(_ mqtt.Client, msg mqtt.Message) {
+		handler(msg.Payload())
+	}
 }
 
-// 消费通道：原始字节
-var SinkRawDataCh = make(chan []byte, 128)
-
 // ---- 提取 payload 的原始 JSON 字节 ----
 func payloadBytes(p interface{}) ([]byte, error) {
 	switch v := p.(type) {
@@ -75,6 +115,14 @@ func payloadBytes(p interface{}) ([]byte, error) {
 	case json.RawMessage:
 		return []byte(v), nil
 	case string:
+		// 与 EdgeX 一致：EDGEX_MSG_BASE64_PAYLOAD=true 时 payload 为 base64 字符串，否则为 JSON 文本
+		if types.IsMsgBase64Payload() {
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("payload 不是合法的 base64（EDGEX_MSG_BASE64_PAYLOAD=true）: %w", err)
+			}
+			return b, nil
+		}
 		return []byte(v), nil
 	case []byte:
 		return v, nil
@@ -99,29 +147,51 @@ func decodeHexFlexible(s string) ([]byte, error) {
 	return hex.DecodeString(s)
 }
 
-// ========== MQTT 回调 ==========
-func sinkMsgHandler(_ mqtt.Client, msg mqtt.Message) {
-	// 解外层
+// 解析 EdgexMessage 外层
+func DecodeEnvelope(body []byte) (EdgexMessage, error) {
 	var env EdgexMessage
-	if err := json.Unmarshal(msg.Payload(), &env); err != nil {
-		log.Printf("❌ 解析 EdgexMessage 失败: %v; payload=%s", err, string(msg.Payload()))
-		return
+	if err := json.Unmarshal(body, &env); err != nil {
+		return env, fmt.Errorf("解析 EdgexMessage 失败: %w; payload=%s", err, string(body))
+	}
+	return env, nil
+}
+
+// 解析 EdgexMessage 外层与 SinkPayload 内层，返回 payload 与原始帧
+func DecodeSinkMessage(body []byte) (SinkPayload, []byte, error) {
+	env, err := DecodeEnvelope(body)
+	if err != nil {
+		return SinkPayload{}, nil, err
+	}
+	return DecodeEnvelopePayload(env.Payload)
+}
+
+// ErrorCode 非 0 的信封中 payload 为错误描述，取出其文本用于日志
+func EnvelopeErrorText(payload interface{}) string {
+	pb, err := payloadBytes(payload)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
 	}
-	pb, err := payloadBytes(env.Payload)
+	return string(pb)
+}
+
+// 解析信封中的 payload（JSON 对象、字符串或字节），MessageBus 传输直接使用
+func DecodeEnvelopePayload(payload interface{}) (SinkPayload, []byte, error) {
+	pb, err := payloadBytes(payload)
 	if err != nil || len(pb) == 0 {
-		log.Printf("❌ 读取内层 payload 失败: %v", err)
-		return
+		return SinkPayload{}, nil, fmt.Errorf("读取内层 payload 失败: %v", err)
 	}
+	return DecodeSinkPayload(pb)
+}
 
-	// 2) 解内层 SinkPayload
+// 解析 SinkPayload 并把 Data 从 HEX 还原为原始帧
+// MQTT 与 TCP/UDP 等传输共用
+func DecodeSinkPayload(pb []byte) (SinkPayload, []byte, error) {
 	var sp SinkPayload
 	if err := json.Unmarshal(pb, &sp); err != nil {
-		log.Printf("❌ 解析 SinkPayload 失败: %v; payload=%s", err, string(pb))
-		return
+		return sp, nil, fmt.Errorf("解析 SinkPayload 失败: %w; payload=%s", err, string(pb))
 	}
 	if sp.Data == "" {
-		log.Printf("⚠ SinkPayload.Data 为空，忽略")
-		return
+		return sp, nil, errors.New("SinkPayload.Data 为空，忽略")
 	}
 	// 仅处理 Type=="sink"
 	if sp.Type != "" && sp.Type != "sink" {
@@ -132,20 +202,13 @@ func sinkMsgHandler(_ mqtt.Client, msg mqtt.Message) {
 	// HEX → 原始字节
 	raw, err := decodeHexFlexible(sp.Data)
 	if err != nil {
-		log.Printf("❌ HEX 解码失败: %v; Data=%q", err, sp.Data)
-		return
+		return sp, nil, fmt.Errorf("HEX 解码失败: %w; Data=%q", err, sp.Data)
 	}
 	// 长度校验（若上游未填或为负，则不校验）
 	if sp.Datalen >= 0 && sp.Datalen != len(raw) {
 		log.Printf("⚠ Datalen(%d) ≠ 实际字节数(%d)", sp.Datalen, len(raw))
 	}
-
-	// 投递到通道
-	select {
-	case SinkRawDataCh <- raw:
-	default:
-		log.Printf("⚠ SinkRawDataCh 已满，丢弃 len=%d", len(raw))
-	}
+	return sp, raw, nil
 }
 
 // 清洗/校验：去空白与常见分隔符、去 0x 前缀；确保偶数长度
@@ -166,48 +229,52 @@ func normalizeHex(s string) (string, []byte, error) {
 	return s, b, err
 }
 
-// 发送至topic
+// 组装下行 SinkPayload
 // - eid:   模块 EID
-// - data:  字符串
-func PublishSinkCommand(client mqtt.Client, topic, eid, data string) error {
+// - data:  HEX 字符串
+func NewSinkPayload(eid, data string) (SinkPayload, error) {
 	//规整 & 校验
 	normHex, raw, err := normalizeHex(data)
 	if err != nil {
-		return fmt.Errorf("invalid hex data: %w", err)
+		return SinkPayload{}, fmt.Errorf("invalid hex data: %w", err)
 	}
-
-	//组内层 payload
-	sp := SinkPayload{
+	return SinkPayload{
 		Type:      "sink",
 		Eid:       eid,
 		Timestamp: uint64(time.Now().Unix()),
 		Datalen:   len(raw), // 字节数
 		Data:      strings.ToUpper(normHex),
+	}, nil
+}
+
+// 组装下行消息（EdgexMessage 外层 + SinkPayload 内层）
+// - id:    下行跟踪 ID，写入 CorrelationID，为空时生成
+// - eid:   模块 EID
+// - data:  HEX 字符串
+func EncodeSinkCommand(id, eid, data string) ([]byte, error) {
+	//组内层 payload
+	sp, err := NewSinkPayload(eid, data)
+	if err != nil {
+		return nil, err
 	}
 
+	if id == "" {
+		id = uuid.NewString()
+	}
 	//外层
 	env := EdgexMessage{
 		ApiVersion:    "v3",
-		CorrelationID: fmt.Sprintf("sink-%d", time.Now().UnixNano()),
-		RequestID:     fmt.Sprintf("req-%d", time.Now().UnixNano()),
+		CorrelationID: id,
+		RequestID:     uuid.NewString(),
 		ErrorCode:     0,
 		Payload:       sp,
 		ContentType:   "application/json",
 	}
 
-	//序列化并发布
+	//序列化
 	body, err := json.Marshal(env)
 	if err != nil {
-		return fmt.Errorf("marshal edgex message: %w", err)
-	}
-
-	token := client.Publish(topic, 0, false, body)
-	token.Wait()
-	return token.Error()
-}
-
-func Close(ms uint) {
-	if MqttClient != nil && MqttClient.IsConnectionOpen() {
-		MqttClient.Disconnect(ms)
+		return nil, fmt.Errorf("marshal edgex message: %w", err)
 	}
+	return body, nil
 }
@@ -0,0 +1,358 @@
+package mqttclient
+
+// MQTT v5 请求/响应：
+// 下行携带 ResponseTopic、CorrelationData 以及用户属性（传感器 EID、帧类型），
+// 网关处理后向 ResponseTopic 发布同一 CorrelationData 的响应，告知投递结果。
+// 响应结果优先取用户属性 result（ok / error）与 reason，缺省时解析 JSON payload {"result":"ok","reason":""}
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/google/uuid"
+)
+
+// 网关响应中的结果取值
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// 网关拒绝或投递失败
+var ErrRejected = errors.New("网关投递失败")
+
+// 网关对一条下行的响应
+type Response struct {
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+}
+
+// 带请求/响应能力的 MQTT v5 连接，断线按退避重连，连上后重新订阅
+type V5Client struct {
+	cfg       autopaho.ClientConfig
+	respTopic string
+
+	mu      sync.Mutex
+	cm      *autopaho.ConnectionManager
+	cancel  context.CancelFunc
+	subs    map[string]v5Subscription
+	pending map[string]chan Response
+	state   string
+	onState []func(state string)
+	closed  bool
+}
+
+type v5Subscription struct {
+	qos     byte
+	handler func(payload []byte)
+}
+
+// respTopic 为本实例接收网关响应的 topic，需保证各实例不同
+func NewV5(o Options, b Backoff, respTopic string) (*V5Client, error) {
+	if b.Min <= 0 {
+		b.Min = time.Second
+	}
+	if b.Max < b.Min {
+		b.Max = b.Min
+	}
+	u, err := url.Parse(o.BrokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("broker 地址非法 %q: %w", o.BrokerURL, err)
+	}
+	tlsCfg, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	c := &V5Client{
+		respTopic: respTopic,
+		subs:      make(map[string]v5Subscription),
+		pending:   make(map[string]chan Response),
+		state:     StateDisconnected,
+	}
+	c.cfg = autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{u},
+		TlsCfg:                        tlsCfg,
+		KeepAlive:                     60,
+		CleanStartOnInitialConnection: true,
+		ConnectTimeout:                connectTimeout,
+		// 首次连接不等待，之后按退避
+		ReconnectBackoff: func(n int) time.Duration {
+			if n == 0 {
+				return 0
+			}
+			return b.delay(n - 1)
+		},
+		ConnectUsername: o.Username,
+		ConnectPassword: []byte(o.Password),
+		OnConnectionUp:  func(cm *autopaho.ConnectionManager, _ *paho.Connack) { c.onConnectionUp(cm) },
+		OnConnectError: func(err error) {
+			log.Printf("❌ MQTT v5 连接失败: %v", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: o.ClientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					c.onPublish(pr.Packet)
+					return true, nil
+				},
+			},
+			OnClientError: func(err error) { c.onConnectionLost(err) },
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				c.onConnectionLost(fmt.Errorf("broker 断开连接，原因码 %d", d.ReasonCode))
+			},
+		},
+	}
+	return c, nil
+}
+
+// 注册连接状态回调，回调在 MQTT 协程中执行，不应阻塞
+func (c *V5Client) OnStateChange(fn func(state string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onState = append(c.onState, fn)
+}
+
+// 开始连接，之后由 autopaho 维护重连
+func (c *V5Client) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cm != nil || c.closed {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.state = StateConnecting
+	cm, err := autopaho.NewConnection(ctx, c.cfg)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("建立 MQTT v5 连接失败: %w", err)
+	}
+	c.cm, c.cancel = cm, cancel
+	return nil
+}
+
+// 连上后订阅响应 topic 及登记的全部 topic
+func (c *V5Client) onConnectionUp(cm *autopaho.ConnectionManager) {
+	c.mu.Lock()
+	opts := []paho.SubscribeOptions{{Topic: c.respTopic, QoS: 1}}
+	for t, s := range c.subs {
+		opts = append(opts, paho.SubscribeOptions{Topic: t, QoS: s.qos})
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if _, err := cm.Subscribe(ctx, &paho.Subscribe{Subscriptions: opts}); err != nil {
+		// 订阅失败时保持 connecting，下行会直接返回未连接
+		log.Printf("❌ MQTT v5 订阅失败: %v", err)
+		return
+	}
+	for _, o := range opts {
+		log.Printf("🔔 订阅数据: %s", o.Topic)
+	}
+	c.setState(StateConnected)
+}
+
+func (c *V5Client) onConnectionLost(err error) {
+	log.Printf("⚠ MQTT v5 连接断开: %v", err)
+	c.setState(StateConnecting)
+}
+
+func (c *V5Client) setState(state string) {
+	c.mu.Lock()
+	if c.closed || c.state == state {
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	cbs := append([]func(string){}, c.onState...)
+	c.mu.Unlock()
+	for _, fn := range cbs {
+		fn(state)
+	}
+}
+
+func (c *V5Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state == StateConnected
+}
+
+// 登记订阅，已连接时立即订阅，之后每次重连自动重新订阅
+func (c *V5Client) Subscribe(topic string, qos byte, handler func(payload []byte)) error {
+	c.mu.Lock()
+	c.subs[topic] = v5Subscription{qos: qos, handler: handler}
+	cm, connected := c.cm, c.state == StateConnected
+	c.mu.Unlock()
+	if !connected {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	_, err := cm.Subscribe(ctx, &paho.Subscribe{Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: qos}}})
+	return err
+}
+
+// 响应 topic 上的消息按 CorrelationData 交给等待的请求，其余按订阅分发
+func (c *V5Client) onPublish(p *paho.Publish) {
+	if p.Topic == c.respTopic {
+		c.onResponse(p)
+		return
+	}
+	c.mu.Lock()
+	s, ok := c.subs[p.Topic]
+	c.mu.Unlock()
+	if ok {
+		s.handler(p.Payload)
+	}
+}
+
+func (c *V5Client) onResponse(p *paho.Publish) {
+	if p.Properties == nil || len(p.Properties.CorrelationData) == 0 {
+		log.Printf("⚠ 收到缺少 CorrelationData 的响应，忽略")
+		return
+	}
+	id := string(p.Properties.CorrelationData)
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if !ok {
+		log.Printf("⚠ 响应 %s 无对应请求（可能已超时）", id)
+		return
+	}
+	// ch 缓冲为 1，且已在锁内移出 pending，每个请求至多写入一次，不会阻塞 MQTT 协程；
+	// 仍用非阻塞写入，防止以后改动破坏该前提
+	select {
+	case ch <- decodeResponse(p):
+	default:
+		log.Printf("⚠ 响应 %s 重复投递，忽略", id)
+	}
+}
+
+func decodeResponse(p *paho.Publish) Response {
+	r := Response{
+		Result: p.Properties.User.Get("result"),
+		Reason: p.Properties.User.Get("reason"),
+	}
+	if r.Result == "" && len(p.Payload) > 0 {
+		if err := json.Unmarshal(p.Payload, &r); err != nil {
+			r = Response{Result: ResultError, Reason: fmt.Sprintf("响应格式非法: %v", err)}
+		}
+	}
+	return r
+}
+
+// 发布一条请求并等待网关响应，超时或网关返回 error 时返回错误
+// id 作为 CorrelationData（为空时生成），props 作为用户属性随请求发送
+func (c *V5Client) Request(ctx context.Context, id, topic string, payload []byte, props map[string]string) error {
+	if id == "" {
+		id = uuid.NewString()
+	}
+	ch, release, err := c.publish(ctx, id, topic, payload, props)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return waitResponse(ctx, id, ch)
+}
+
+// 发布一条请求后即返回，不等待网关响应；响应到达或 timeout 到期后在独立 goroutine 中以结果回调 done。
+// 发布本身失败时直接返回错误，不回调
+func (c *V5Client) RequestAsync(id, topic string, payload []byte, props map[string]string, timeout time.Duration, done func(err error)) error {
+	if id == "" {
+		id = uuid.NewString()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ch, release, err := c.publish(ctx, id, topic, payload, props)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		defer cancel()
+		defer release()
+		done(waitResponse(ctx, id, ch))
+	}()
+	return nil
+}
+
+// 登记等待的响应并以 QoS 1 发布请求，release 注销等待
+func (c *V5Client) publish(ctx context.Context, id, topic string, payload []byte, props map[string]string) (<-chan Response, func(), error) {
+	c.mu.Lock()
+	cm, connected := c.cm, c.state == StateConnected
+	c.mu.Unlock()
+	if !connected {
+		return nil, nil, ErrNotConnected
+	}
+	ch := make(chan Response, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	release := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	user := make(paho.UserProperties, 0, len(props))
+	for k, v := range props {
+		user.Add(k, v)
+	}
+	_, err := cm.Publish(ctx, &paho.Publish{
+		Topic:   topic,
+		QoS:     1,
+		Payload: payload,
+		Properties: &paho.PublishProperties{
+			ResponseTopic:   c.respTopic,
+			CorrelationData: []byte(id),
+			ContentType:     "application/json",
+			User:            user,
+		},
+	})
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("发布到 %s 失败: %w", topic, err)
+	}
+	return ch, release, nil
+}
+
+func waitResponse(ctx context.Context, id string, ch <-chan Response) error {
+	select {
+	case r := <-ch:
+		if r.Result != ResultOK {
+			if r.Reason == "" {
+				r.Reason = r.Result
+			}
+			return fmt.Errorf("%w: %s", ErrRejected, r.Reason)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待网关响应 %s 超时: %w", id, ctx.Err())
+	}
+}
+
+// 停止重连并断开
+func (c *V5Client) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	cm, cancel := c.cm, c.cancel
+	c.mu.Unlock()
+	if cm == nil {
+		return
+	}
+	ctx, done := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer done()
+	_ = cm.Disconnect(ctx)
+	cancel()
+}
@@ -0,0 +1,123 @@
+package mqttclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+)
+
+const testRespTopic = "wiresink/resp/test"
+
+func newTestV5(t *testing.T) *V5Client {
+	t.Helper()
+	c, err := NewV5(Options{BrokerURL: "tcp://127.0.0.1:1883", ClientID: "test"}, Backoff{}, testRespTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func response(id string, user map[string]string, payload string) *paho.Publish {
+	props := &paho.PublishProperties{CorrelationData: []byte(id)}
+	for k, v := range user {
+		props.User.Add(k, v)
+	}
+	return &paho.Publish{Topic: testRespTopic, Payload: []byte(payload), Properties: props}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	cases := []struct {
+		user    map[string]string
+		payload string
+		want    Response
+	}{
+		{map[string]string{"result": "ok"}, "", Response{Result: ResultOK}},
+		{map[string]string{"result": "error", "reason": "传感器离线"}, `{"result":"ok"}`, Response{Result: ResultError, Reason: "传感器离线"}},
+		{nil, `{"result":"error","reason":"忙"}`, Response{Result: ResultError, Reason: "忙"}},
+		{nil, "", Response{}},
+	}
+	for _, c := range cases {
+		if got := decodeResponse(response("id", c.user, c.payload)); got != c.want {
+			t.Errorf("decodeResponse(%v, %q) = %+v，期望 %+v", c.user, c.payload, got, c.want)
+		}
+	}
+	if got := decodeResponse(response("id", nil, "not json")); got.Result != ResultError || got.Reason == "" {
+		t.Errorf("非法 payload 应返回 error，实际 %+v", got)
+	}
+}
+
+func TestWaitResponse(t *testing.T) {
+	ch := make(chan Response, 1)
+	ch <- Response{Result: ResultOK}
+	if err := waitResponse(context.Background(), "a", ch); err != nil {
+		t.Fatal(err)
+	}
+	ch <- Response{Result: ResultError, Reason: "拒绝"}
+	if err := waitResponse(context.Background(), "b", ch); !errors.Is(err, ErrRejected) {
+		t.Fatalf("err = %v，期望 ErrRejected", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := waitResponse(ctx, "c", ch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v，期望超时", err)
+	}
+}
+
+// 响应按 CorrelationData 交给等待的请求，重复或无主的响应不阻塞
+func TestOnResponse(t *testing.T) {
+	c := newTestV5(t)
+	ch := make(chan Response, 1)
+	c.pending["req-1"] = ch
+
+	done := make(chan struct{})
+	go func() {
+		c.onPublish(response("req-1", map[string]string{"result": "ok"}, ""))
+		c.onPublish(response("req-1", map[string]string{"result": "ok"}, ""))
+		c.onPublish(response("unknown", nil, ""))
+		c.onPublish(&paho.Publish{Topic: testRespTopic})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("响应处理被阻塞")
+	}
+	if r := <-ch; r.Result != ResultOK {
+		t.Fatalf("响应 = %+v", r)
+	}
+	if len(c.pending) != 0 {
+		t.Fatalf("响应后仍有 %d 个等待", len(c.pending))
+	}
+}
+
+// 非响应 topic 按订阅分发
+func TestOnPublishDispatch(t *testing.T) {
+	c := newTestV5(t)
+	var got []byte
+	if err := c.Subscribe("wiresink/up", 1, func(p []byte) { got = p }); err != nil {
+		t.Fatal(err)
+	}
+	c.onPublish(&paho.Publish{Topic: "wiresink/up", Payload: []byte("frame")})
+	c.onPublish(&paho.Publish{Topic: "other", Payload: []byte("x")})
+	if string(got) != "frame" {
+		t.Fatalf("订阅收到 %q", got)
+	}
+}
+
+func TestRequestNotConnected(t *testing.T) {
+	c := newTestV5(t)
+	if err := c.Request(context.Background(), "", "wiresink/down", nil, nil); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("Request = %v，期望 ErrNotConnected", err)
+	}
+	called := false
+	err := c.RequestAsync("", "wiresink/down", nil, nil, time.Second, func(error) { called = true })
+	if !errors.Is(err, ErrNotConnected) || called {
+		t.Fatalf("RequestAsync = %v, called=%v", err, called)
+	}
+	if len(c.pending) != 0 {
+		t.Fatal("发布失败不应留下等待")
+	}
+}
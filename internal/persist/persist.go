@@ -0,0 +1,140 @@
+package persist
+
+// 运行时状态快照：EID 映射、传感器最近值、拓扑、暂存下行及重组中的分片缓存，
+// 定期写入本地文件（写临时文件后改名），服务启动时恢复，超过保留期的内容丢弃
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/config"
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/frameparser"
+	"github.com/linjuya-lu/device-wiresink-go/internal/sfqueue"
+)
+
+// 快照格式版本，不一致时不恢复
+const version = 1
+
+// 资源值，Type 记录 Go 类型以便按原类型恢复
+type value struct {
+	Device   string          `json:"device"`
+	Resource string          `json:"resource"`
+	Type     string          `json:"type"`
+	Value    json.RawMessage `json:"value"`
+	Updated  time.Time       `json:"updated"`
+}
+
+type snapshot struct {
+	Version   int                    `json:"version"`
+	Saved     time.Time              `json:"saved"`
+	Values    []value                `json:"values"`
+	Eids      map[string]string      `json:"eids"`
+	Topology  []config.NodeTopology  `json:"topology"`
+	Held      []sfqueue.Item         `json:"held"`
+	Downlinks []downlink.Record      `json:"downlinks"`
+	Fragments []frameparser.Fragment `json:"fragments"`
+}
+
+// 恢复结果
+type Stats struct {
+	Values    int
+	Eids      int
+	Topology  int
+	Held      int
+	Fragments int
+}
+
+// 收集当前运行时状态并写入 path
+func Save(path string) error {
+	s := snapshot{
+		Version:  version,
+		Saved:    time.Now(),
+		Eids:     config.Devices().EIDs(),
+		Topology: config.GetTopoList(),
+		Held:     sfqueue.Pending(""),
+	}
+	for _, v := range config.Devices().Stored() {
+		typ, raw, err := encodeValue(v.Value)
+		if err != nil {
+			log.Printf("⚠ 资源 %s.%s 的值无法保存: %v", v.Device, v.Resource, err)
+			continue
+		}
+		s.Values = append(s.Values, value{Device: v.Device, Resource: v.Resource, Type: typ, Value: raw, Updated: v.Updated})
+	}
+	for _, it := range s.Held {
+		if r, ok := downlink.Get(it.ID); ok {
+			s.Downlinks = append(s.Downlinks, r)
+		}
+	}
+	s.Fragments = frameparser.Fragments()
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("序列化运行时状态失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// 从 path 恢复运行时状态，文件不存在时不做任何事；
+// 传感器值按各自更新时间、EID 映射与拓扑按快照时间判断是否超过保留期 retention
+func Load(path string, retention time.Duration) (Stats, error) {
+	var st Stats
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	var s snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return st, fmt.Errorf("解析运行时状态 %s 失败: %w", path, err)
+	}
+	if s.Version != version {
+		return st, fmt.Errorf("运行时状态 %s 版本 %d 不支持", path, s.Version)
+	}
+	cutoff := time.Now().Add(-retention)
+
+	for _, v := range s.Values {
+		if v.Updated.Before(cutoff) {
+			continue
+		}
+		val, err := decodeValue(v.Type, v.Value)
+		if err != nil {
+			log.Printf("⚠ 资源 %s.%s 的值无法恢复: %v", v.Device, v.Resource, err)
+			continue
+		}
+		config.Devices().Restore(config.StoredValue{Device: v.Device, Resource: v.Resource, Value: val, Updated: v.Updated})
+		st.Values++
+	}
+	if s.Saved.After(cutoff) {
+		for eid, name := range s.Eids {
+			if _, ok := config.Devices().Lookup(eid); !ok {
+				config.Devices().MapEID(eid, name)
+				st.Eids++
+			}
+		}
+		if len(s.Topology) > 0 && len(config.GetTopoList()) == 0 {
+			config.SetTopoList(s.Topology)
+			st.Topology = len(s.Topology)
+		}
+	}
+	// 暂存下行按各自有效期，分片缓存按重组超时判断
+	for _, r := range s.Downlinks {
+		downlink.Restore(r)
+	}
+	st.Held = sfqueue.Restore(s.Held)
+	st.Fragments = frameparser.RestoreFragments(s.Fragments)
+	return st, nil
+}
@@ -0,0 +1,95 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 设备存储中出现的值类型，与 Profile 的 ValueType 及解析结果对应
+func encodeValue(v interface{}) (string, json.RawMessage, error) {
+	var typ string
+	switch v.(type) {
+	case string:
+		typ = "string"
+	case bool:
+		typ = "bool"
+	case float32:
+		typ = "float32"
+	case float64:
+		typ = "float64"
+	case int:
+		typ = "int"
+	case int8:
+		typ = "int8"
+	case int16:
+		typ = "int16"
+	case int32:
+		typ = "int32"
+	case int64:
+		typ = "int64"
+	case uint8:
+		typ = "uint8"
+	case uint16:
+		typ = "uint16"
+	case uint32:
+		typ = "uint32"
+	case uint64:
+		typ = "uint64"
+	case []byte:
+		typ = "bytes"
+	case []float32:
+		typ = "float32array"
+	case map[string]interface{}:
+		typ = "object"
+	default:
+		return "", nil, fmt.Errorf("不支持的类型 %T", v)
+	}
+	raw, err := json.Marshal(v)
+	return typ, raw, err
+}
+
+func decodeValue(typ string, raw json.RawMessage) (interface{}, error) {
+	switch typ {
+	case "string":
+		return decode[string](raw)
+	case "bool":
+		return decode[bool](raw)
+	case "float32":
+		return decode[float32](raw)
+	case "float64":
+		return decode[float64](raw)
+	case "int":
+		return decode[int](raw)
+	case "int8":
+		return decode[int8](raw)
+	case "int16":
+		return decode[int16](raw)
+	case "int32":
+		return decode[int32](raw)
+	case "int64":
+		return decode[int64](raw)
+	case "uint8":
+		return decode[uint8](raw)
+	case "uint16":
+		return decode[uint16](raw)
+	case "uint32":
+		return decode[uint32](raw)
+	case "uint64":
+		return decode[uint64](raw)
+	case "bytes":
+		return decode[[]byte](raw)
+	case "float32array":
+		return decode[[]float32](raw)
+	case "object":
+		return decode[map[string]interface{}](raw)
+	}
+	return nil, fmt.Errorf("未知类型 %q", typ)
+}
+
+func decode[T any](raw json.RawMessage) (interface{}, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
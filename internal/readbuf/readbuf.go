@@ -0,0 +1,318 @@
+package readbuf
+
+// 上报缓冲：解析出的读数先追加到本地文件，再由单独协程按顺序交给 SDK。
+// SDK 管道阻塞（发布协程全部占满，如消息总线发布缓慢）时解析协程不再被拖住，
+// 读数在磁盘上排队，恢复后按入队顺序补报；超过上限时丢弃最旧的读数。
+// 交给 SDK 即视为已交付：SDK 不回报发布结果，发布失败的事件由 SDK 记录日志后丢弃，
+// 本缓冲无法重发。已交付位置定期落盘，异常退出后可能重复上报少量读数
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓冲已关闭
+var ErrClosed = errors.New("上报缓冲已关闭")
+
+const (
+	// 每交付若干条保存一次已交付位置
+	headSaveEvery = 64
+	// 已交付部分超过该大小且超过文件一半时压缩文件
+	compactSize = 4 << 20
+)
+
+// 一个资源值，Type 为 EdgeX ValueType
+type Value struct {
+	Name  string            `json:"name"`
+	Type  string            `json:"type"`
+	Value json.RawMessage   `json:"value"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+// 一次上报
+type Reading struct {
+	Device string  `json:"device"`
+	Source string  `json:"source"`
+	Origin int64   `json:"origin"`
+	Values []Value `json:"values"`
+}
+
+// 缓冲状态
+type Stats struct {
+	Depth   int        `json:"depth"`   // 待交付条数
+	Max     int        `json:"max"`     // 上限
+	Dropped uint64     `json:"dropped"` // 因超过上限丢弃的条数
+	Oldest  *time.Time `json:"oldest,omitempty"`
+	Bytes   int64      `json:"bytes"` // 缓冲文件大小
+}
+
+// 磁盘缓冲，path 为追加写入的日志文件，path+".head" 记录已交付位置
+type Buffer struct {
+	mu      sync.Mutex
+	path    string
+	max     int
+	file    *os.File
+	size    int64 // 文件大小
+	head    int64 // 第一条未交付读数的偏移
+	offsets []int64
+	origins []int64
+	pops    int
+	saved   int64 // 已落盘的交付位置
+	dropped uint64
+	closed  bool
+	notify  chan struct{}
+}
+
+// 打开缓冲并恢复上次未交付的读数
+func Open(path string, max int) (*Buffer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	b := &Buffer{path: path, max: max, file: f, notify: make(chan struct{}, 1)}
+	if err := b.recover(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("恢复上报缓冲 %s 失败: %w", path, err)
+	}
+	if n := len(b.offsets); n > 0 {
+		log.Printf("ℹ 上报缓冲中有 %d 条未交付的读数", n)
+		b.signal()
+	}
+	return b, nil
+}
+
+// 从已交付位置扫描到文件末尾，重建各条读数的偏移；末尾写了一半的行截掉
+func (b *Buffer) recover() error {
+	if raw, err := os.ReadFile(b.path + ".head"); err == nil {
+		b.head, _ = strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		b.saved = b.head
+	}
+	info, err := b.file.Stat()
+	if err != nil {
+		return err
+	}
+	if b.head < 0 || b.head > info.Size() {
+		b.head = 0
+	}
+	if _, err := b.file.Seek(b.head, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(b.file)
+	off := b.head
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var rd Reading
+		if json.Unmarshal(line, &rd) == nil {
+			b.offsets = append(b.offsets, off)
+			b.origins = append(b.origins, rd.Origin)
+		}
+		off += int64(len(line))
+	}
+	if off != info.Size() {
+		log.Printf("⚠ 上报缓冲 %s 末尾有不完整的记录，已截断", b.path)
+		if err := b.file.Truncate(off); err != nil {
+			return err
+		}
+	}
+	b.size = off
+	return nil
+}
+
+// 追加一条读数，超过上限时丢弃最旧的一条
+func (b *Buffer) Push(r Reading) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	if _, err := b.file.WriteAt(line, b.size); err != nil {
+		return err
+	}
+	b.offsets = append(b.offsets, b.size)
+	b.origins = append(b.origins, r.Origin)
+	b.size += int64(len(line))
+	if b.max > 0 && len(b.offsets) > b.max {
+		b.dropped++
+		if b.dropped == 1 || b.dropped%1000 == 0 {
+			log.Printf("⚠ 上报缓冲已满(%d)，已丢弃最旧的读数 %d 条", b.max, b.dropped)
+		}
+		b.advanceLocked()
+	}
+	b.signal()
+	return nil
+}
+
+func (b *Buffer) signal() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 最旧的一条未交付读数，缓冲为空时等待，stop 关闭或缓冲关闭时返回错误
+func (b *Buffer) Peek(stop <-chan struct{}) (Reading, error) {
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return Reading{}, ErrClosed
+		}
+		if len(b.offsets) > 0 {
+			r, err := b.readLocked(0)
+			b.mu.Unlock()
+			return r, err
+		}
+		b.mu.Unlock()
+		select {
+		case <-b.notify:
+		case <-stop:
+			return Reading{}, ErrClosed
+		}
+	}
+}
+
+func (b *Buffer) readLocked(i int) (Reading, error) {
+	end := b.size
+	if i+1 < len(b.offsets) {
+		end = b.offsets[i+1]
+	}
+	buf := make([]byte, end-b.offsets[i])
+	if _, err := b.file.ReadAt(buf, b.offsets[i]); err != nil {
+		return Reading{}, err
+	}
+	var r Reading
+	err := json.Unmarshal(buf, &r)
+	return r, err
+}
+
+// 最旧的一条已交付，移出缓冲
+func (b *Buffer) Pop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.offsets) > 0 {
+		b.advanceLocked()
+	}
+}
+
+func (b *Buffer) advanceLocked() {
+	b.offsets = b.offsets[1:]
+	b.origins = b.origins[1:]
+	if len(b.offsets) == 0 {
+		// 全部交付，清空文件
+		b.offsets, b.origins = nil, nil
+		b.head, b.size = 0, 0
+		if err := b.file.Truncate(0); err != nil {
+			log.Printf("❌ 清空上报缓冲 %s 失败: %v", b.path, err)
+		}
+		b.saveHeadLocked()
+		return
+	}
+	b.head = b.offsets[0]
+	if b.head > compactSize && b.head > b.size/2 {
+		b.compactLocked()
+		return
+	}
+	if b.pops++; b.pops%headSaveEvery == 0 {
+		b.saveHeadLocked()
+	}
+}
+
+// 把未交付部分移到新文件开头
+func (b *Buffer) compactLocked() {
+	rest := make([]byte, b.size-b.head)
+	if _, err := b.file.ReadAt(rest, b.head); err != nil {
+		log.Printf("❌ 压缩上报缓冲 %s 失败: %v", b.path, err)
+		return
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, rest, 0o644); err != nil {
+		log.Printf("❌ 压缩上报缓冲 %s 失败: %v", b.path, err)
+		return
+	}
+	// 先把位置记为 0 再改名：中途退出最多重复上报，不会漏报
+	saved := b.head
+	b.head = 0
+	b.saveHeadLocked()
+	if err := os.Rename(tmp, b.path); err != nil {
+		b.head = saved
+		b.saveHeadLocked()
+		log.Printf("❌ 压缩上报缓冲 %s 失败: %v", b.path, err)
+		return
+	}
+	f, err := os.OpenFile(b.path, os.O_RDWR, 0o644)
+	if err != nil {
+		log.Printf("❌ 重新打开上报缓冲 %s 失败: %v", b.path, err)
+		return
+	}
+	b.file.Close()
+	b.file = f
+	shift := saved
+	for i := range b.offsets {
+		b.offsets[i] -= shift
+	}
+	b.size -= shift
+}
+
+func (b *Buffer) saveHeadLocked() {
+	if b.head == b.saved {
+		return
+	}
+	b.saved = b.head
+	if err := os.WriteFile(b.path+".head", []byte(strconv.FormatInt(b.head, 10)), 0o644); err != nil {
+		log.Printf("❌ 保存上报缓冲位置失败: %v", err)
+	}
+}
+
+// 当前状态
+func (b *Buffer) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Stats{Depth: len(b.offsets), Max: b.max, Dropped: b.dropped, Bytes: b.size}
+	if len(b.origins) > 0 {
+		t := time.Unix(0, b.origins[0])
+		s.Oldest = &t
+	}
+	return s
+}
+
+// 待交付条数
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.offsets)
+}
+
+// 保存已交付位置并关闭文件，未交付的读数留待下次启动
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.saveHeadLocked()
+	return b.file.Close()
+}
@@ -0,0 +1,146 @@
+package readbuf
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func reading(i int, source string) Reading {
+	return Reading{
+		Device: "sensor",
+		Source: source,
+		Origin: int64(i),
+		Values: []Value{{Name: "Temperature", Type: "Float32", Value: json.RawMessage("1.5")}},
+	}
+}
+
+func open(t *testing.T, path string, max int) *Buffer {
+	t.Helper()
+	b, err := Open(path, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// 依次取出 n 条并核对 Origin
+func popOrigins(t *testing.T, b *Buffer, want ...int64) {
+	t.Helper()
+	for _, o := range want {
+		r, err := b.Peek(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Origin != o {
+			t.Fatalf("Origin = %d，期望 %d", r.Origin, o)
+		}
+		b.Pop()
+	}
+}
+
+// 重新打开后从已交付位置继续
+func TestRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buf.log")
+	b := open(t, path, 0)
+	for i := 1; i <= 4; i++ {
+		if err := b.Push(reading(i, "s")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	popOrigins(t, b, 1, 2)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Push(reading(5, "s")); err != ErrClosed {
+		t.Fatalf("关闭后 Push = %v，期望 ErrClosed", err)
+	}
+
+	b = open(t, path, 0)
+	defer b.Close()
+	if n := b.Len(); n != 2 {
+		t.Fatalf("恢复 %d 条，期望 2 条", n)
+	}
+	popOrigins(t, b, 3, 4)
+	if st := b.Stats(); st.Depth != 0 || st.Bytes != 0 {
+		t.Fatalf("全部交付后 Stats = %+v", st)
+	}
+}
+
+// 末尾写了一半的记录被截掉，之后的追加不受影响
+func TestRecoverTruncatesPartialRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buf.log")
+	b := open(t, path, 0)
+	for i := 1; i <= 2; i++ {
+		if err := b.Push(reading(i, "s")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := b.Stats().Bytes
+	b.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"device":"sensor","sour`)
+	f.Close()
+
+	b = open(t, path, 0)
+	defer b.Close()
+	if st := b.Stats(); st.Depth != 2 || st.Bytes != size {
+		t.Fatalf("截断后 Stats = %+v，期望 2 条 %d 字节", st, size)
+	}
+	if err := b.Push(reading(3, "s")); err != nil {
+		t.Fatal(err)
+	}
+	popOrigins(t, b, 1, 2, 3)
+}
+
+// 超过上限时丢弃最旧的
+func TestOverflowDropsOldest(t *testing.T) {
+	b := open(t, filepath.Join(t.TempDir(), "buf.log"), 2)
+	defer b.Close()
+	for i := 1; i <= 3; i++ {
+		if err := b.Push(reading(i, "s")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := b.Stats(); st.Depth != 2 || st.Dropped != 1 {
+		t.Fatalf("Stats = %+v", st)
+	}
+	popOrigins(t, b, 2, 3)
+}
+
+// 已交付部分超过 compactSize 后压缩文件，偏移随之平移，重启后仍能恢复
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buf.log")
+	b := open(t, path, 0)
+	big := strings.Repeat("x", 64<<10)
+	n := compactSize/len(big) + 4
+	for i := 1; i <= n; i++ {
+		if err := b.Push(reading(i, big)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var want []int64
+	for i := 1; i <= n-2; i++ {
+		want = append(want, int64(i))
+	}
+	popOrigins(t, b, want...)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := b.Stats(); info.Size() != st.Bytes || st.Depth != 2 || st.Bytes >= compactSize {
+		t.Fatalf("压缩后文件 %d 字节，Stats = %+v", info.Size(), st)
+	}
+	b.Close()
+
+	b = open(t, path, 0)
+	defer b.Close()
+	popOrigins(t, b, int64(n-1), int64(n))
+}
@@ -1,16 +1,173 @@
 package relay
 
 import (
-	"encoding/hex"
-	"strings"
+	"errors"
+	"log"
+	"sync"
+	"time"
 
-	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+	"github.com/linjuya-lu/device-wiresink-go/internal/airtime"
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/sfqueue"
+	"github.com/linjuya-lu/device-wiresink-go/internal/transport"
 )
 
-func SendFrame(dstAddr string, payload []byte) {
-	eidStr := "238A0841D828"
+// 尚未设置传输
+var ErrNoTransport = errors.New("下行传输未初始化")
 
-	hexStr1 := strings.ToUpper(hex.EncodeToString(payload))
+// 下行优先级：复位、告警最先，其次各类设置，例行查询在后，批量任务最后且按间隔下发
+const (
+	PriorityBulk    = -1
+	PriorityRoutine = 0
+	PrioritySetting = 1
+	PriorityUrgent  = 2
+)
+
+var (
+	mu     sync.RWMutex
+	active transport.Transport
+)
+
+func init() {
+	// 休眠传感器上线后经当前传输补发暂存的下行
+	sfqueue.SetHandlers(func(id, eid string, frame []byte, priority int) error {
+		t := Transport()
+		if t == nil {
+			downlink.Fail(id, ErrNoTransport)
+			return ErrNoTransport
+		}
+		// 转入断线缓存后由传输负责补发，不算失败
+		if err := send(t, id, eid, frame, priority, false); !errors.Is(err, transport.ErrQueued) {
+			return err
+		}
+		return nil
+	}, downlink.Fail)
+}
+
+// 设置当前下行传输，由驱动在 Initialize 时调用
+func SetTransport(t transport.Transport) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = t
+}
+
+// 获取当前下行传输
+func Transport() transport.Transport {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active
+}
+
+// 下发选项
+type Options struct {
+	Priority int           // 越大越先下发，见 Priority* 常量
+	TTL      time.Duration // 休眠传感器暂存有效期，0 使用默认值
+	Confirm  bool          // 命令下行：传输支持时（transport.ConfirmSender）等待网关投递结果
+}
+
+// 一次下发的结果
+type Result struct {
+	ID     string // 下行跟踪 ID
+	Held   bool   // 传感器休眠，已暂存待其上线后下发
+	Queued bool   // 传输断线，已进入断线缓存待补发，此时同时返回 transport.ErrQueued
+}
+
+// 经当前传输把 payload 下发给 dstAddr，每条下行登记跟踪 ID
+// 断线缓存的下行返回 transport.ErrQueued，跟踪状态保持 queued，待补发后再推进；调度排队期间阻塞
+func SendFrame(dstAddr string, payload []byte) error {
+	_, err := Deliver(dstAddr, payload, Options{})
+	return err
+}
+
+// 同 SendFrame，并返回跟踪 ID 以及是否因传感器休眠而暂存
+func Deliver(dstAddr string, payload []byte, o Options) (Result, error) {
+	t := Transport()
+	if t == nil {
+		id := downlink.Track(dstAddr, payload, "")
+		downlink.Fail(id, ErrNoTransport)
+		log.Printf("❌ 下发到 %s 失败: %v", dstAddr, ErrNoTransport)
+		return Result{ID: id}, ErrNoTransport
+	}
+	id := downlink.Track(dstAddr, payload, t.Name())
+	if sfqueue.ShouldHold(dstAddr) {
+		if err := sfqueue.Hold(id, dstAddr, payload, o.Priority, o.TTL); err != nil {
+			downlink.Fail(id, err)
+			return Result{ID: id}, err
+		}
+		return Result{ID: id, Held: true}, nil
+	}
+	err := send(t, id, dstAddr, payload, o.Priority, o.Confirm)
+	return Result{ID: id, Queued: errors.Is(err, transport.ErrQueued)}, err
+}
+
+// 下发协议应答（数据上传应答、分片应答）：传感器不会回复应答，不登记下行跟踪，
+// 也不因休眠暂存——应答总是发给刚上报的传感器。
+// 排队后即返回，不阻塞解析；发送失败只记录日志
+func SendAck(dstAddr string, payload []byte) error {
+	t := Transport()
+	if t == nil {
+		log.Printf("❌ 应答 %s 失败: %v", dstAddr, ErrNoTransport)
+		return ErrNoTransport
+	}
+	s := schedulerFor(dstAddr)
+	if s == nil {
+		go transmit(t, "", dstAddr, payload, false)
+		return nil
+	}
+	err := s.Submit(airtime.Job{
+		Eid:      dstAddr,
+		Frame:    payload,
+		Priority: PriorityUrgent,
+		Send: func() error {
+			return transmit(t, "", dstAddr, payload, false)
+		},
+	})
+	if err != nil {
+		log.Printf("❌ 应答 %s 未调度: %v", dstAddr, err)
+	}
+	return err
+}
+
+// 经调度器排队后发送，调度拒绝时下行记为失败
+func send(t transport.Transport, id, dstAddr string, payload []byte, priority int, confirm bool) error {
+	s := schedulerFor(dstAddr)
+	if s == nil {
+		return transmit(t, id, dstAddr, payload, confirm)
+	}
+	sent := false
+	err := s.Do(airtime.Job{
+		ID:       id,
+		Eid:      dstAddr,
+		Frame:    payload,
+		Priority: priority,
+		Send: func() error {
+			sent = true
+			return transmit(t, id, dstAddr, payload, confirm)
+		},
+	})
+	if err != nil && !sent {
+		downlink.Fail(id, err)
+		log.Printf("❌ 下发到 %s 未调度: %v", dstAddr, err)
+	}
+	return err
+}
 
-	mqttclient.PublishSinkCommand(mqttclient.MqttClient, "edgex/server/response/device_wiresink/down", eidStr, hexStr1)
+func transmit(t transport.Transport, id, dstAddr string, payload []byte, confirm bool) error {
+	var err error
+	if cs, ok := t.(transport.ConfirmSender); ok && confirm {
+		err = cs.SendConfirmed(id, dstAddr, payload)
+	} else {
+		err = t.Send(id, dstAddr, payload)
+	}
+	switch {
+	case errors.Is(err, transport.ErrQueued):
+		log.Printf("ℹ [%s] 下发到 %s 已进入断线缓存: %s", t.Name(), dstAddr, id)
+		return err
+	case err != nil:
+		downlink.Fail(id, err)
+		log.Printf("❌ [%s] 下发到 %s 失败: %v", t.Name(), dstAddr, err)
+		return err
+	}
+	downlink.Update(id, downlink.StatusSent, nil)
+	return nil
 }
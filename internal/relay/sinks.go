@@ -0,0 +1,101 @@
+package relay
+
+// 按汇聚节点调度下行：每个汇聚节点各有一个调度器，空口预算互不占用。
+// 传感器最近一次经哪个汇聚节点上报，下行就排入该节点的调度器；尚未见过上行的传感器归入默认汇聚节点
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/airtime"
+)
+
+var (
+	sinkMu      sync.Mutex
+	airtimeCfg  *airtime.Config // nil 时不调度，直接发送
+	defaultSink string
+	routes      = make(map[string]string) // 传感器 EID → 汇聚节点 EID
+	schedulers  = make(map[string]*airtime.Scheduler)
+)
+
+// 设置下行调度参数及默认汇聚节点，运行时修改对已创建的各调度器立即生效
+func ConfigureAirtime(defaultSinkEid string, c airtime.Config) {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	airtimeCfg = &c
+	defaultSink = strings.ToUpper(defaultSinkEid)
+	for _, s := range schedulers {
+		s.Configure(c)
+	}
+}
+
+// 记录传感器上行所经的汇聚节点，sinkEid 为空时忽略
+func Route(sensorEid, sinkEid string) {
+	if sinkEid == "" {
+		return
+	}
+	sensorEid, sinkEid = strings.ToUpper(sensorEid), strings.ToUpper(sinkEid)
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	if old, ok := routes[sensorEid]; ok && old != sinkEid {
+		log.Printf("ℹ 传感器 %s 改经汇聚节点 %s 上报（原 %s）", sensorEid, sinkEid, old)
+	}
+	routes[sensorEid] = sinkEid
+}
+
+// 传感器所属的汇聚节点 EID：最近一次上行所经的节点，尚未见过上行时为默认汇聚节点
+func SinkOf(sensorEid string) string {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	return sinkOfLocked(sensorEid)
+}
+
+func sinkOfLocked(eid string) string {
+	if sink, ok := routes[strings.ToUpper(eid)]; ok {
+		return sink
+	}
+	return defaultSink
+}
+
+// 是否为默认汇聚节点或有传感器经其上报过的汇聚节点
+func KnownSink(sinkEid string) bool {
+	sinkEid = strings.ToUpper(sinkEid)
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	if sinkEid == defaultSink {
+		return true
+	}
+	for _, sink := range routes {
+		if sink == sinkEid {
+			return true
+		}
+	}
+	return false
+}
+
+// 停止所有调度器，排队中的下行返回失败；之后的下行不再调度
+func CloseSchedulers() {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	for sink, s := range schedulers {
+		s.Close()
+		delete(schedulers, sink)
+	}
+	airtimeCfg = nil
+}
+
+// 传感器所属汇聚节点的调度器，首次使用时创建；未配置调度时返回 nil
+func schedulerFor(eid string) *airtime.Scheduler {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	if airtimeCfg == nil {
+		return nil
+	}
+	sink := sinkOfLocked(eid)
+	s, ok := schedulers[sink]
+	if !ok {
+		s = airtime.New(sink, *airtimeCfg)
+		schedulers[sink] = s
+	}
+	return s
+}
@@ -0,0 +1,23 @@
+package relay
+
+import (
+	"testing"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/airtime"
+)
+
+func TestSinkOf(t *testing.T) {
+	ConfigureAirtime("aaaaaaaaaaaa", airtime.Config{})
+	defer CloseSchedulers()
+	Route("0a0b0c0d0e0f", "BBBBBBBBBBBB")
+
+	if s := SinkOf("0A0B0C0D0E0F"); s != "BBBBBBBBBBBB" {
+		t.Fatalf("SinkOf = %s，期望上行所经的 BBBBBBBBBBBB", s)
+	}
+	if s := SinkOf("010203040506"); s != "AAAAAAAAAAAA" {
+		t.Fatalf("SinkOf = %s，未见过上行应归入默认汇聚节点", s)
+	}
+	if !KnownSink("aaaaaaaaaaaa") || !KnownSink("BBBBBBBBBBBB") || KnownSink("CCCCCCCCCCCC") {
+		t.Fatal("KnownSink 结果不符")
+	}
+}
@@ -0,0 +1,272 @@
+package serialport
+
+// 通过串口直接驱动汇聚模块（AT 指令）
+// 下行：AT+DTX=<addr>,<hex>，等待 OK / ERROR
+// 上行：模块主动上报 +DRX:<addr>,[<len>,]<hex>
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 等待模块应答 OK/ERROR 的超时时间
+	atResponseTimeout = 3 * time.Second
+	// 上行数据前缀
+	drxPrefix = "+DRX"
+	// 单行上限，超长行丢弃到下一个换行，避免异常输出耗尽内存或中断读取
+	maxLineLen = 64 * 1024
+	// 读取出错（如 USB 串口拔出）后重新打开的退避时间，逐次翻倍
+	reopenMin = time.Second
+	reopenMax = 30 * time.Second
+)
+
+// 模块执行 AT 命令返回 ERROR
+var ErrATCommand = errors.New("AT 命令执行失败")
+
+// 收到一条 +DRX 上行时的回调：addr 为来源地址，frame 为原始帧
+type FrameHandler func(addr string, frame []byte)
+
+// 串口连接，读取出错时按退避重新打开，直到 Close
+type Port struct {
+	path      string
+	open      func() (io.ReadWriteCloser, error) // 重新打开串口，nil 时读取出错即停止
+	reopenMin time.Duration
+	reopenMax time.Duration
+	rwMu      sync.Mutex // 保护 rw，重新打开时替换
+	rw        io.ReadWriteCloser
+	mu        sync.Mutex // 同一时刻只允许一条 AT 命令等待应答
+	respCh    chan error // OK → nil，ERROR → ErrATCommand
+	once      sync.Once
+	done      chan struct{}
+}
+
+// 打开串口，baud 为波特率（如 115200）
+// path 也可以是伪终端（/dev/pts/N），便于在没有模块时联调
+func Open(path string, baud int) (*Port, error) {
+	open := func() (io.ReadWriteCloser, error) {
+		f, err := openRaw(path, baud)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	rw, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return newPort(path, rw, open), nil
+}
+
+// rw 为已打开的串口，open 用于出错后重新打开；测试中可用 net.Pipe 代替
+func newPort(path string, rw io.ReadWriteCloser, open func() (io.ReadWriteCloser, error)) *Port {
+	return &Port{
+		path:      path,
+		open:      open,
+		reopenMin: reopenMin,
+		reopenMax: reopenMax,
+		rw:        rw,
+		respCh:    make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// 当前打开的串口
+func (p *Port) conn() io.ReadWriteCloser {
+	p.rwMu.Lock()
+	defer p.rwMu.Unlock()
+	return p.rw
+}
+
+// 启动读协程，+DRX 解析出的原始帧交给 handler
+func (p *Port) Start(handler FrameHandler) {
+	go p.readLoop(handler)
+}
+
+// 关闭串口，读协程随之退出
+func (p *Port) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.done)
+		err = p.conn().Close()
+	})
+	return err
+}
+
+// 以 AT+DTX 下发一帧并等待模块应答
+func (p *Port) Send(addr string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.exec(FormatDTX(addr, payload)); err != nil {
+		return fmt.Errorf("串口 %s 下发失败: %w", p.path, err)
+	}
+	return nil
+}
+
+// 格式化下行 AT 命令：\rAT+DTX=<addr>,<HEX>\r\n
+func FormatDTX(addr string, payload []byte) []byte {
+	hexStr := strings.ToUpper(hex.EncodeToString(payload))
+	return []byte(fmt.Sprintf("\rAT+DTX=%s,%s\r\n", addr, hexStr))
+}
+
+// 写出一条命令并等待 OK/ERROR
+func (p *Port) exec(cmd []byte) error {
+	// 丢弃上一条超时后才到达的应答
+	select {
+	case <-p.respCh:
+	default:
+	}
+	if _, err := p.conn().Write(cmd); err != nil {
+		return fmt.Errorf("写串口失败: %w", err)
+	}
+	timer := time.NewTimer(atResponseTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-p.respCh:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("等待模块应答超时(%s)", atResponseTimeout)
+	case <-p.done:
+		return os.ErrClosed
+	}
+}
+
+// 读取模块输出，出错后重新打开串口继续读取，Close 后退出
+func (p *Port) readLoop(handler FrameHandler) {
+	rw := p.conn()
+	for {
+		err := p.readLines(rw, handler)
+		select {
+		case <-p.done:
+			return
+		default:
+		}
+		if p.open == nil {
+			log.Printf("❌ 串口 %s 读取结束: %v", p.path, err)
+			return
+		}
+		log.Printf("❌ 串口 %s 读取中断: %v，准备重新打开", p.path, err)
+		rw.Close()
+		if rw = p.reopen(); rw == nil {
+			return
+		}
+	}
+}
+
+// 按退避重新打开串口并替换当前连接，Close 后返回 nil
+func (p *Port) reopen() io.ReadWriteCloser {
+	delay := p.reopenMin
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-p.done:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		rw, err := p.open()
+		if err != nil {
+			delay = min(2*delay, p.reopenMax)
+			log.Printf("⚠ 重新打开串口 %s 失败: %v，%s 后重试", p.path, err, delay)
+			continue
+		}
+		p.rwMu.Lock()
+		select {
+		case <-p.done:
+			p.rwMu.Unlock()
+			rw.Close()
+			return nil
+		default:
+		}
+		p.rw = rw
+		p.rwMu.Unlock()
+		log.Printf("✅ 串口 %s 已重新打开", p.path)
+		return rw
+	}
+}
+
+// 按行读取模块输出，直到读取出错
+func (p *Port) readLines(rw io.Reader, handler FrameHandler) error {
+	r := bufio.NewReaderSize(rw, maxLineLen)
+	var err error
+	for {
+		var b []byte
+		b, err = r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			n := len(b)
+			for err == bufio.ErrBufferFull {
+				b, err = r.ReadSlice('\n')
+				n += len(b)
+			}
+			log.Printf("⚠ 串口 %s 行长度超过 %d 字节，丢弃 %d 字节", p.path, maxLineLen, n)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil && len(b) == 0 {
+			return err
+		}
+		line := strings.TrimSpace(string(b))
+		switch {
+		case line == "":
+			continue
+		case line == "OK":
+			p.respond(nil)
+		case line == "ERROR" || strings.HasPrefix(line, "+CME ERROR"):
+			p.respond(fmt.Errorf("%w: %s", ErrATCommand, line))
+		case strings.HasPrefix(line, drxPrefix):
+			addr, frame, err := ParseDRX(line)
+			if err != nil {
+				log.Printf("❌ 解析上行数据失败: %v; line=%q", err, line)
+				continue
+			}
+			handler(addr, frame)
+		default:
+			log.Printf("ℹ 串口未识别的输出: %q", line)
+		}
+	}
+}
+
+// 把应答交给正在等待的 exec
+func (p *Port) respond(err error) {
+	select {
+	case p.respCh <- err:
+	default:
+	}
+}
+
+// 解析 +DRX 上行行
+// 支持 +DRX:<addr>,<hex>、+DRX=<addr>,<hex> 以及带长度字段的 +DRX:<addr>,<len>,<hex>
+func ParseDRX(line string) (string, []byte, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(line), drxPrefix)
+	rest = strings.TrimLeft(rest, ":= ")
+	fields := strings.Split(rest, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return "", nil, fmt.Errorf("字段个数错误: %d", len(fields))
+	}
+	addr := strings.ToUpper(strings.TrimSpace(fields[0]))
+	hexStr := strings.TrimSpace(fields[len(fields)-1])
+	frame, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return "", nil, fmt.Errorf("HEX 解码失败: %w", err)
+	}
+	if len(fields) == 3 {
+		n, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return "", nil, fmt.Errorf("长度字段非法: %q", fields[1])
+		}
+		if n != len(frame) {
+			return "", nil, fmt.Errorf("长度字段(%d) ≠ 实际字节数(%d)", n, len(frame))
+		}
+	}
+	return addr, frame, nil
+}
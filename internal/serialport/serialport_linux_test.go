@@ -0,0 +1,76 @@
+//go:build linux
+
+package serialport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 打开一对伪终端，返回主端与从端路径；环境不支持时跳过
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("无法打开 /dev/ptmx: %v", err)
+	}
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		unix.Close(fd)
+		t.Skipf("解锁伪终端失败: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		unix.Close(fd)
+		t.Skipf("读取伪终端编号失败: %v", err)
+	}
+	master := os.NewFile(uintptr(fd), "ptmx")
+	t.Cleanup(func() { master.Close() })
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// 经伪终端走真实的 Open（原始模式、波特率设置）完成一次 AT 下行与 +DRX 上行
+func TestPTYExchange(t *testing.T) {
+	master, path := openPTY(t)
+	p, err := Open(path, 115200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if _, err := Open(path, 1234); err == nil {
+		t.Fatal("不支持的波特率应报错")
+	}
+
+	got := make(chan []byte, 1)
+	p.Start(func(addr string, frame []byte) { got <- frame })
+
+	cmds := make(chan string, 1)
+	go func() {
+		r := bufio.NewReader(master)
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmds <- string(bytes.TrimSpace([]byte(line)))
+		master.Write([]byte("\r\nOK\r\n+DRX:238A0841D828,2,A55A\r\n"))
+	}()
+	if err := p.Send("238A0841D828", []byte{0x01, 0xAB}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if cmd := <-cmds; cmd != "AT+DTX=238A0841D828,01AB" {
+		t.Fatalf("命令 = %q", cmd)
+	}
+	select {
+	case frame := <-got:
+		if !bytes.Equal(frame, []byte{0xA5, 0x5A}) {
+			t.Fatalf("上行 = %x", frame)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到上行")
+	}
+}
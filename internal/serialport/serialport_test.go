@@ -0,0 +1,202 @@
+package serialport
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 模拟汇聚模块：在 net.Pipe 的另一端读 AT 命令并按 reply 应答
+func fakeModule(t *testing.T, reply func(cmd string) string) (*Port, net.Conn) {
+	t.Helper()
+	host, module := net.Pipe()
+	p := newPort("pipe", host, nil)
+	t.Cleanup(func() {
+		p.Close()
+		module.Close()
+	})
+	go func() {
+		r := bufio.NewReader(module)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := string(bytes.TrimSpace([]byte(line)))
+			if cmd == "" {
+				continue
+			}
+			if _, err := module.Write([]byte(reply(cmd))); err != nil {
+				return
+			}
+		}
+	}()
+	return p, module
+}
+
+func TestSendOK(t *testing.T) {
+	cmds := make(chan string, 1)
+	p, _ := fakeModule(t, func(cmd string) string {
+		cmds <- cmd
+		return "\r\nOK\r\n"
+	})
+	p.Start(func(string, []byte) {})
+
+	if err := p.Send("238A0841D828", []byte{0x01, 0xAB}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got, want := <-cmds, "AT+DTX=238A0841D828,01AB"; got != want {
+		t.Fatalf("命令 = %q, 期望 %q", got, want)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	p, _ := fakeModule(t, func(string) string { return "+CME ERROR: 3\r\n" })
+	p.Start(func(string, []byte) {})
+
+	err := p.Send("238A0841D828", []byte{0x01})
+	if !errors.Is(err, ErrATCommand) {
+		t.Fatalf("Send 错误 = %v, 期望 ErrATCommand", err)
+	}
+}
+
+func TestUplinkDRX(t *testing.T) {
+	p, module := fakeModule(t, func(string) string { return "OK\r\n" })
+	type uplink struct {
+		addr  string
+		frame []byte
+	}
+	got := make(chan uplink, 1)
+	p.Start(func(addr string, frame []byte) { got <- uplink{addr, frame} })
+
+	// 未识别的输出和格式错误的 +DRX 被跳过，不影响后续上行
+	if _, err := module.Write([]byte("RING\r\n+DRX:238a,zz\r\n+DRX:238a0841d828,3,0102ff\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case u := <-got:
+		if u.addr != "238A0841D828" || !bytes.Equal(u.frame, []byte{0x01, 0x02, 0xFF}) {
+			t.Fatalf("上行 = %s %x", u.addr, u.frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未收到上行")
+	}
+}
+
+func TestParseDRX(t *testing.T) {
+	cases := []struct {
+		line  string
+		addr  string
+		frame []byte
+		ok    bool
+	}{
+		{"+DRX:238A0841D828,0102", "238A0841D828", []byte{1, 2}, true},
+		{"+DRX=238a0841d828,2,0102", "238A0841D828", []byte{1, 2}, true},
+		{"+DRX:238A0841D828,3,0102", "", nil, false},
+		{"+DRX:238A0841D828", "", nil, false},
+		{"+DRX:238A0841D828,xyz", "", nil, false},
+	}
+	for _, c := range cases {
+		addr, frame, err := ParseDRX(c.line)
+		if (err == nil) != c.ok {
+			t.Errorf("%q: err = %v", c.line, err)
+			continue
+		}
+		if c.ok && (addr != c.addr || !bytes.Equal(frame, c.frame)) {
+			t.Errorf("%q: 得到 %s %x", c.line, addr, frame)
+		}
+	}
+}
+
+// 超长行丢弃后继续读取，不中断串口
+func TestLineOverflow(t *testing.T) {
+	p, module := fakeModule(t, func(string) string { return "OK\r\n" })
+	got := make(chan string, 1)
+	p.Start(func(addr string, frame []byte) { got <- addr })
+
+	go func() {
+		module.Write(bytes.Repeat([]byte{'A'}, 2*maxLineLen))
+		module.Write([]byte("\r\n+DRX:238A0841D828,01\r\n"))
+	}()
+	select {
+	case addr := <-got:
+		if addr != "238A0841D828" {
+			t.Fatalf("addr = %s", addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("超长行之后未收到上行")
+	}
+}
+
+// 读取出错（如 USB 串口拔出）后按退避重新打开，之后的上行与下行照常
+func TestReopenAfterReadError(t *testing.T) {
+	host1, module1 := net.Pipe()
+	host2, module2 := net.Pipe()
+	opens := make(chan struct{}, 4)
+	attempt := 0
+	p := newPort("pipe", host1, func() (io.ReadWriteCloser, error) {
+		opens <- struct{}{}
+		if attempt++; attempt == 1 {
+			return nil, errors.New("设备不存在")
+		}
+		return host2, nil
+	})
+	p.reopenMin, p.reopenMax = 5*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		p.Close()
+		module2.Close()
+	})
+	got := make(chan string, 1)
+	p.Start(func(addr string, frame []byte) { got <- addr })
+
+	module1.Close()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-opens:
+		case <-time.After(time.Second):
+			t.Fatalf("第 %d 次重新打开未发生", i+1)
+		}
+	}
+
+	go func() {
+		r := bufio.NewReader(module2)
+		if _, err := r.ReadString('\n'); err == nil {
+			module2.Write([]byte("OK\r\n+DRX:238A0841D828,01\r\n"))
+		}
+	}()
+	if err := p.Send("238A0841D828", []byte{0x01}); err != nil {
+		t.Fatalf("重新打开后 Send: %v", err)
+	}
+	select {
+	case addr := <-got:
+		if addr != "238A0841D828" {
+			t.Fatalf("addr = %s", addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("重新打开后未收到上行")
+	}
+}
+
+// 无法重新打开时 Close 仍能让读协程退出
+func TestCloseDuringReopen(t *testing.T) {
+	host, module := net.Pipe()
+	opened := make(chan struct{}, 16)
+	p := newPort("pipe", host, func() (io.ReadWriteCloser, error) {
+		opened <- struct{}{}
+		return nil, errors.New("设备不存在")
+	})
+	p.reopenMin, p.reopenMax = 5*time.Millisecond, 5*time.Millisecond
+	p.Start(func(string, []byte) {})
+	module.Close()
+	<-opened
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send("238A0841D828", []byte{1}); err == nil {
+		t.Fatal("Close 后 Send 应失败")
+	}
+}
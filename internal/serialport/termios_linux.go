@@ -0,0 +1,55 @@
+//go:build linux
+
+package serialport
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// 支持的波特率
+var baudRates = map[int]uint32{
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+	460800: unix.B460800,
+	921600: unix.B921600,
+}
+
+// 以非阻塞方式打开串口并设置为 8N1 原始模式
+// 非阻塞 fd 交给 os.File 后由 runtime poller 调度，Close 可以打断阻塞中的 Read
+func openRaw(path string, baud int) (*os.File, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("不支持的波特率: %d", baud)
+	}
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("打开串口 %s 失败: %w", path, err)
+	}
+	tio, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("读取串口 %s 参数失败: %w", path, err)
+	}
+	// 等价于 cfmakeraw
+	tio.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	tio.Oflag &^= unix.OPOST
+	tio.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	tio.Cflag &^= unix.CSIZE | unix.PARENB | unix.CSTOPB | unix.CBAUD
+	tio.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | speed
+	tio.Ispeed = speed
+	tio.Ospeed = speed
+	tio.Cc[unix.VMIN] = 1
+	tio.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, tio); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("设置串口 %s 参数失败: %w", path, err)
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package serialport
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func openRaw(path string, baud int) (*os.File, error) {
+	return nil, fmt.Errorf("串口传输暂不支持 %s 平台", runtime.GOOS)
+}
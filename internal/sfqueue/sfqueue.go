@@ -0,0 +1,361 @@
+package sfqueue
+
+// 休眠传感器的下行暂存（store-and-forward）：
+// 低功耗传感器只在上报后短暂接收，发往它们的下行先按 EID 暂存，
+// 解析到该 EID 的上行后在接收窗口内按优先级依次下发；超过有效期的下行由定时器丢弃
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 暂存下行过期或被取消
+var (
+	ErrExpired   = errors.New("暂存下行已过期")
+	ErrCancelled = errors.New("暂存下行已取消")
+	ErrOverflow  = errors.New("暂存队列已满，被丢弃")
+)
+
+// 暂存参数
+type Config struct {
+	// 休眠传感器 EID
+	Eids []string
+	// 收到上行后可直接下发的时间窗口
+	Window time.Duration
+	// 未指定有效期时的默认值
+	TTL time.Duration
+	// 每个传感器最多暂存条数，满时丢弃优先级最低、最晚入队的一条
+	MaxPerSensor int
+}
+
+// 一条暂存的下行
+type Item struct {
+	ID       string    `json:"id"`
+	Eid      string    `json:"eid"`
+	Priority int       `json:"priority"` // 越大越先下发
+	Frame    []byte    `json:"frame"`
+	Enqueued time.Time `json:"enqueued"`
+	Expires  time.Time `json:"expires"`
+}
+
+var (
+	mu       sync.Mutex
+	cfg      Config
+	sleepy   = make(map[string]bool)
+	lastSeen = make(map[string]time.Time)
+	queues   = make(map[string][]*Item)
+	// 在最早的有效期到达时触发过期清理
+	expiry *time.Timer
+	// 下行发送与丢弃回调，由 relay 设置
+	sender  func(id, eid string, frame []byte, priority int) error
+	dropper func(id string, cause error)
+)
+
+// 更新暂存参数，运行时修改即生效
+func Configure(c Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = c
+	sleepy = make(map[string]bool, len(c.Eids))
+	for _, eid := range c.Eids {
+		sleepy[strings.ToUpper(eid)] = true
+	}
+}
+
+// 设置释放时的发送函数及过期、丢弃时的回调
+func SetHandlers(send func(id, eid string, frame []byte, priority int) error, drop func(id string, cause error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	sender, dropper = send, drop
+}
+
+// eid 是休眠传感器时返回其上行后的接收窗口
+func ReceiveWindow(eid string) (time.Duration, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !sleepy[strings.ToUpper(eid)] {
+		return 0, false
+	}
+	return cfg.Window, true
+}
+
+// eid 是休眠传感器且当前不在接收窗口内，下行需要暂存
+func ShouldHold(eid string) bool {
+	eid = strings.ToUpper(eid)
+	mu.Lock()
+	defer mu.Unlock()
+	if !sleepy[eid] {
+		return false
+	}
+	t, ok := lastSeen[eid]
+	return !ok || time.Since(t) > cfg.Window
+}
+
+// 暂存一条下行，ttl<=0 时使用默认有效期。
+// 队列已满且本条优先级最低时不暂存，返回 ErrOverflow；否则挤出的旧下行经丢弃回调标记失败
+func Hold(id, eid string, frame []byte, priority int, ttl time.Duration) error {
+	eid = strings.ToUpper(eid)
+	now := time.Now()
+	mu.Lock()
+	if ttl <= 0 {
+		ttl = cfg.TTL
+	}
+	it := &Item{
+		ID:       id,
+		Eid:      eid,
+		Priority: priority,
+		Frame:    append([]byte(nil), frame...),
+		Enqueued: now,
+		Expires:  now.Add(ttl),
+	}
+	q := append(queues[eid], it)
+	sortQueue(q)
+	var dropped *Item
+	if cfg.MaxPerSensor > 0 && len(q) > cfg.MaxPerSensor {
+		dropped = q[len(q)-1]
+		q = q[:len(q)-1]
+	}
+	if dropped == it {
+		limit := cfg.MaxPerSensor
+		mu.Unlock()
+		log.Printf("⚠ 传感器 %s 暂存已满(%d)，新下行 %s 未暂存", eid, limit, id)
+		return ErrOverflow
+	}
+	queues[eid] = q
+	armLocked()
+	drop := dropper
+	mu.Unlock()
+
+	log.Printf("ℹ 传感器 %s 休眠中，下行已暂存（优先级 %d，待发 %d 条）", eid, priority, len(q))
+	if dropped != nil && drop != nil {
+		log.Printf("⚠ 传感器 %s 暂存已满(%d)，丢弃 %s", eid, cfg.MaxPerSensor, dropped.ID)
+		drop(dropped.ID, ErrOverflow)
+	}
+	return nil
+}
+
+// 优先级高的在前，同优先级先入先出
+func sortQueue(q []*Item) {
+	sort.SliceStable(q, func(i, j int) bool {
+		if q[i].Priority != q[j].Priority {
+			return q[i].Priority > q[j].Priority
+		}
+		return q[i].Enqueued.Before(q[j].Enqueued)
+	})
+}
+
+// 收到 eid 的上行：打开接收窗口并依次下发暂存的下行
+func Seen(eid string) {
+	eid = strings.ToUpper(eid)
+	now := time.Now()
+	mu.Lock()
+	if !sleepy[eid] {
+		mu.Unlock()
+		return
+	}
+	lastSeen[eid] = now
+	q := queues[eid]
+	delete(queues, eid)
+	armLocked()
+	send, drop := sender, dropper
+	mu.Unlock()
+	if len(q) == 0 {
+		return
+	}
+	go release(eid, q, send, drop)
+}
+
+// 依次下发，每条之前确认接收窗口仍打开；窗口已过则把余下的放回暂存，等下次上线
+func release(eid string, q []*Item, send func(id, eid string, frame []byte, priority int) error, drop func(id string, cause error)) {
+	sent := 0
+	for i, it := range q {
+		if ShouldHold(eid) {
+			log.Printf("ℹ 传感器 %s 接收窗口已关闭，已下发 %d 条，其余 %d 条重新暂存", eid, sent, len(q)-i)
+			rehold(eid, q[i:], drop)
+			return
+		}
+		if time.Now().After(it.Expires) {
+			if drop != nil {
+				drop(it.ID, ErrExpired)
+			}
+			continue
+		}
+		if send == nil {
+			continue
+		}
+		if err := send(it.ID, eid, it.Frame, it.Priority); err != nil {
+			log.Printf("❌ 下发暂存下行 %s 到 %s 失败: %v", it.ID, eid, err)
+			continue
+		}
+		sent++
+	}
+	log.Printf("ℹ 传感器 %s 已上线，下发暂存下行 %d/%d 条", eid, sent, len(q))
+}
+
+// 把未下发的下行放回 eid 的暂存队列，与期间新暂存的合并排序，超出上限的经丢弃回调标记失败
+func rehold(eid string, rest []*Item, drop func(id string, cause error)) {
+	mu.Lock()
+	q := append(queues[eid], rest...)
+	sortQueue(q)
+	var dropped []*Item
+	if cfg.MaxPerSensor > 0 && len(q) > cfg.MaxPerSensor {
+		dropped = append(dropped, q[cfg.MaxPerSensor:]...)
+		q = q[:cfg.MaxPerSensor]
+	}
+	queues[eid] = q
+	armLocked()
+	mu.Unlock()
+	for _, it := range dropped {
+		log.Printf("⚠ 传感器 %s 暂存已满，丢弃 %s", eid, it.ID)
+		if drop != nil {
+			drop(it.ID, ErrOverflow)
+		}
+	}
+}
+
+// 清理过期的暂存下行，调用方持有 mu
+func expire() []*Item {
+	now := time.Now()
+	var expired []*Item
+	for eid, q := range queues {
+		kept := q[:0]
+		for _, it := range q {
+			if !now.Before(it.Expires) {
+				expired = append(expired, it)
+				continue
+			}
+			kept = append(kept, it)
+		}
+		if len(kept) == 0 {
+			delete(queues, eid)
+		} else {
+			queues[eid] = kept
+		}
+	}
+	return expired
+}
+
+// 按最早的有效期重设过期定时器，调用方持有 mu
+func armLocked() {
+	var next time.Time
+	for _, q := range queues {
+		for _, it := range q {
+			if next.IsZero() || it.Expires.Before(next) {
+				next = it.Expires
+			}
+		}
+	}
+	if expiry != nil {
+		expiry.Stop()
+		expiry = nil
+	}
+	if !next.IsZero() {
+		expiry = time.AfterFunc(time.Until(next), sweep)
+	}
+}
+
+// 定时器到期：丢弃过期的暂存下行并标记失败
+func sweep() {
+	mu.Lock()
+	expired := expire()
+	armLocked()
+	drop := dropper
+	mu.Unlock()
+	for _, it := range expired {
+		log.Printf("⚠ 传感器 %s 的暂存下行 %s 已过期", it.Eid, it.ID)
+		if drop != nil {
+			drop(it.ID, ErrExpired)
+		}
+	}
+}
+
+// 暂存中的下行，eid 为空时返回全部；按 EID、下发顺序排列
+func Pending(eid string) []Item {
+	eid = strings.ToUpper(eid)
+	mu.Lock()
+	expired := expire()
+	armLocked()
+	drop := dropper
+	eids := make([]string, 0, len(queues))
+	for k := range queues {
+		if eid == "" || k == eid {
+			eids = append(eids, k)
+		}
+	}
+	sort.Strings(eids)
+	var out []Item
+	for _, k := range eids {
+		for _, it := range queues[k] {
+			out = append(out, *it)
+		}
+	}
+	mu.Unlock()
+	if drop != nil {
+		for _, it := range expired {
+			drop(it.ID, ErrExpired)
+		}
+	}
+	return out
+}
+
+// 取消一条暂存下行
+func Cancel(id string) bool {
+	mu.Lock()
+	var found bool
+	for eid, q := range queues {
+		for i, it := range q {
+			if it.ID != id {
+				continue
+			}
+			queues[eid] = append(q[:i], q[i+1:]...)
+			if len(queues[eid]) == 0 {
+				delete(queues, eid)
+			}
+			found = true
+			break
+		}
+		if found {
+			break
+		}
+	}
+	if found {
+		armLocked()
+	}
+	drop := dropper
+	mu.Unlock()
+	if found && drop != nil {
+		drop(id, ErrCancelled)
+	}
+	return found
+}
+
+// 恢复重启前暂存的下行，已过期或已存在的跳过，返回恢复条数
+func Restore(items []Item) int {
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	exists := make(map[string]bool)
+	for _, q := range queues {
+		for _, it := range q {
+			exists[it.ID] = true
+		}
+	}
+	n := 0
+	for _, it := range items {
+		if exists[it.ID] || now.After(it.Expires) {
+			continue
+		}
+		it.Eid = strings.ToUpper(it.Eid)
+		queues[it.Eid] = append(queues[it.Eid], &it)
+		exists[it.ID] = true
+		n++
+	}
+	for _, q := range queues {
+		sortQueue(q)
+	}
+	armLocked()
+	return n
+}
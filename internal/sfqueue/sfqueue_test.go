@@ -0,0 +1,180 @@
+package sfqueue
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testEid = "0A0B0C0D0E0F"
+
+// 清空包级状态并设置参数，返回记录发送与丢弃的回调
+func setup(t *testing.T, c Config, sendDelay time.Duration) *calls {
+	t.Helper()
+	mu.Lock()
+	lastSeen = make(map[string]time.Time)
+	queues = make(map[string][]*Item)
+	if expiry != nil {
+		expiry.Stop()
+		expiry = nil
+	}
+	mu.Unlock()
+	Configure(c)
+	r := &calls{dropped: make(map[string]error), done: make(chan struct{}, 16)}
+	SetHandlers(func(id, eid string, frame []byte, priority int) error {
+		time.Sleep(sendDelay)
+		r.mu.Lock()
+		r.sent = append(r.sent, id)
+		r.mu.Unlock()
+		r.done <- struct{}{}
+		return nil
+	}, func(id string, cause error) {
+		r.mu.Lock()
+		r.dropped[id] = cause
+		r.mu.Unlock()
+	})
+	return r
+}
+
+type calls struct {
+	mu      sync.Mutex
+	sent    []string
+	dropped map[string]error
+	done    chan struct{}
+}
+
+func (r *calls) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.done:
+		case <-time.After(time.Second):
+			t.Fatalf("只下发了 %d 条，期望 %d 条", i, n)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+func (r *calls) droppedCause(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped[id]
+}
+
+func TestHoldUntilSeen(t *testing.T) {
+	r := setup(t, Config{Eids: []string{testEid}, Window: time.Second, TTL: time.Minute}, 0)
+	if !ShouldHold(testEid) {
+		t.Fatal("未上线的休眠传感器应暂存下行")
+	}
+	if ShouldHold("FFFFFFFFFFFF") {
+		t.Fatal("非休眠传感器不应暂存")
+	}
+	for _, h := range []struct {
+		id       string
+		priority int
+	}{{"low", 0}, {"high", 5}, {"low2", 0}} {
+		if err := Hold(h.id, testEid, []byte{1}, h.priority, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(Pending(testEid)); n != 3 {
+		t.Fatalf("暂存 %d 条，期望 3 条", n)
+	}
+
+	Seen(testEid)
+	sent := r.wait(t, 3)
+	want := []string{"high", "low", "low2"}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("下发顺序 = %v，期望 %v", sent, want)
+		}
+	}
+	if ShouldHold(testEid) {
+		t.Fatal("接收窗口内不应暂存")
+	}
+	if n := len(Pending("")); n != 0 {
+		t.Fatalf("下发后仍暂存 %d 条", n)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	r := setup(t, Config{Eids: []string{testEid}, Window: time.Second, TTL: time.Minute, MaxPerSensor: 2}, 0)
+	if err := Hold("a", testEid, nil, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Hold("b", testEid, nil, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// 优先级更高，挤出最低的 b
+	if err := Hold("c", testEid, nil, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(r.droppedCause("b"), ErrOverflow) {
+		t.Fatalf("b 应因队列已满被丢弃，cause=%v", r.droppedCause("b"))
+	}
+	// 优先级最低的新下行不暂存
+	if err := Hold("d", testEid, nil, 0, 0); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Hold = %v，期望 ErrOverflow", err)
+	}
+	if n := len(Pending(testEid)); n != 2 {
+		t.Fatalf("暂存 %d 条，期望 2 条", n)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	r := setup(t, Config{Eids: []string{testEid}, Window: time.Second, TTL: 20 * time.Millisecond}, 0)
+	if err := Hold("a", testEid, nil, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for r.droppedCause("a") == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !errors.Is(r.droppedCause("a"), ErrExpired) {
+		t.Fatalf("过期下行未标记失败，cause=%v", r.droppedCause("a"))
+	}
+	if n := len(Pending(testEid)); n != 0 {
+		t.Fatalf("过期后仍暂存 %d 条", n)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	r := setup(t, Config{Eids: []string{testEid}, Window: time.Second, TTL: time.Minute}, 0)
+	if err := Hold("a", testEid, nil, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !Cancel("a") || Cancel("a") {
+		t.Fatal("Cancel 应只成功一次")
+	}
+	if !errors.Is(r.droppedCause("a"), ErrCancelled) {
+		t.Fatalf("cause=%v，期望 ErrCancelled", r.droppedCause("a"))
+	}
+}
+
+// 逐条下发耗时超过接收窗口时，余下的重新暂存
+func TestReleaseStopsAfterWindow(t *testing.T) {
+	r := setup(t, Config{Eids: []string{testEid}, Window: 30 * time.Millisecond, TTL: time.Minute}, 50*time.Millisecond)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := Hold(id, testEid, nil, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Seen(testEid)
+	if sent := r.wait(t, 1); len(sent) != 1 || sent[0] != "a" {
+		t.Fatalf("下发 = %v，期望 [a]", sent)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(Pending(testEid)) != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	pending := Pending(testEid)
+	if len(pending) != 2 || pending[0].ID != "b" || pending[1].ID != "c" {
+		t.Fatalf("重新暂存 = %+v，期望 b、c", pending)
+	}
+	if !ShouldHold(testEid) {
+		t.Fatal("窗口关闭后应继续暂存")
+	}
+}
@@ -0,0 +1,80 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoopbackRoundTrip(t *testing.T) {
+	lb := NewLoopback()
+	if err := lb.Send("id-0", "238A0841D828", []byte{1}); err == nil {
+		t.Fatal("未启动时 Send 应失败")
+	}
+	if err := lb.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer lb.Close()
+
+	// 上行：Inject → Frames
+	up := []byte{0x23, 0x8A, 0x08, 0x41, 0xD8, 0x28, 0x00}
+	if err := lb.Inject("SINK01", up); err != nil {
+		t.Fatal(err)
+	}
+	up[0] = 0 // Inject 须复制，调用方修改不影响已投递的帧
+	select {
+	case f := <-lb.Frames():
+		if f.Transport != NameLoopback || f.SinkEid != "SINK01" || f.Data[0] != 0x23 {
+			t.Fatalf("上行帧 = %+v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未收到上行帧")
+	}
+
+	// 下行：Send → Sent，OnSend 可模拟网关应答
+	var replied []byte
+	lb.OnSend(func(eid string, frame []byte) error {
+		replied = frame
+		return lb.Inject("SINK01", append([]byte{0xFF}, frame...))
+	})
+	down := []byte{0x01, 0x02}
+	if err := lb.Send("id-1", "238A0841D828", down); err != nil {
+		t.Fatal(err)
+	}
+	sent := <-lb.Sent()
+	if sent.ID != "id-1" || sent.Eid != "238A0841D828" || !bytes.Equal(sent.Frame, down) {
+		t.Fatalf("下行帧 = %+v", sent)
+	}
+	if !bytes.Equal(replied, down) {
+		t.Fatalf("OnSend 收到 %x", replied)
+	}
+	if f := <-lb.Frames(); !bytes.Equal(f.Data, []byte{0xFF, 0x01, 0x02}) {
+		t.Fatalf("应答帧 = %x", f.Data)
+	}
+}
+
+func TestLoopbackSendError(t *testing.T) {
+	lb := NewLoopback()
+	lb.Start()
+	defer lb.Close()
+	want := errors.New("网关拒绝")
+	lb.OnSend(func(string, []byte) error { return want })
+	if err := lb.Send("id", "238A0841D828", []byte{1}); !errors.Is(err, want) {
+		t.Fatalf("Send 错误 = %v", err)
+	}
+}
+
+func TestLoopbackClose(t *testing.T) {
+	lb := NewLoopback()
+	lb.Start()
+	lb.Close()
+	if _, ok := <-lb.Frames(); ok {
+		t.Fatal("Close 后 Frames 应关闭")
+	}
+	if err := lb.Inject("SINK01", []byte{1}); err == nil {
+		t.Fatal("Close 后 Inject 应失败")
+	}
+	// 重复 Close 不 panic
+	lb.Close()
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-messaging/v4/messaging"
+	"github.com/edgexfoundry/go-mod-messaging/v4/pkg/types"
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+)
+
+// 通过 EdgeX MessageBus（MQTT / NATS，含安全连接）与汇聚网关交互
+// 信封由 go-mod-messaging 统一封装，CorrelationID 为下行跟踪 ID，
+// 网关以同一 CorrelationID 上报的消息视为确认，ErrorCode 非 0 表示投递失败
+type MessageBus struct {
+	client    messaging.MessageClient
+	upTopic   string
+	downTopic string
+	sinkEid   string // 非空时下行统一填写网关 EID
+	frames    *frameQueue
+
+	msgCh chan types.MessageEnvelope
+	errCh chan error
+	done  chan struct{}
+	once  sync.Once
+}
+
+// client 需已连接，归本传输所有，Close 时断开；topic 为完整 topic（含 BaseTopicPrefix）
+func NewMessageBus(client messaging.MessageClient, upTopic, downTopic, sinkEid string) *MessageBus {
+	return &MessageBus{
+		client:    client,
+		upTopic:   upTopic,
+		downTopic: downTopic,
+		sinkEid:   sinkEid,
+		frames:    newFrameQueue(),
+		msgCh:     make(chan types.MessageEnvelope, frameBufferSize),
+		errCh:     make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+func (t *MessageBus) Name() string { return NameMessageBus }
+
+func (t *MessageBus) Start() error {
+	log.Printf("🔔 订阅数据(MessageBus): %s", t.upTopic)
+	topics := []types.TopicChannel{{Topic: t.upTopic, Messages: t.msgCh}}
+	if err := t.client.Subscribe(topics, t.errCh); err != nil {
+		return err
+	}
+	go t.receiveLoop()
+	return nil
+}
+
+func (t *MessageBus) receiveLoop() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case err := <-t.errCh:
+			log.Printf("❌ MessageBus 订阅错误: %v", err)
+		case env := <-t.msgCh:
+			acked := ackDownlink(env.CorrelationID, env.ErrorCode)
+			if env.ErrorCode != 0 {
+				rejectErrorEnvelope(NameMessageBus, env.CorrelationID, env.ErrorCode, env.Payload, acked)
+				continue
+			}
+			sp, raw, err := mqttclient.DecodeEnvelopePayload(env.Payload)
+			if err != nil {
+				// 纯确认消息不带帧
+				if !acked {
+					log.Printf("❌ %v", err)
+				}
+				continue
+			}
+			f := Frame{
+				Transport: NameMessageBus,
+				SinkEid:   sp.Eid,
+				Type:      sp.Type,
+				Timestamp: sp.Timestamp,
+				Received:  time.Now(),
+				Data:      raw,
+			}
+			if !t.frames.push(f) {
+				log.Printf("⚠ MessageBus 上行通道已满，丢弃 len=%d", len(raw))
+			}
+		}
+	}
+}
+
+func (t *MessageBus) Send(id, eid string, frame []byte) error {
+	if t.sinkEid != "" {
+		eid = t.sinkEid
+	}
+	sp, err := mqttclient.NewSinkPayload(eid, hex.EncodeToString(frame))
+	if err != nil {
+		return err
+	}
+	env := types.NewMessageEnvelopeForRequest(sp, nil)
+	if id != "" { // 未跟踪的下行（协议应答）保留信封生成的 ID
+		env.CorrelationID = id
+	}
+	return t.client.Publish(env, t.downTopic)
+}
+
+func (t *MessageBus) Frames() <-chan Frame { return t.frames.ch }
+
+func (t *MessageBus) Close() error {
+	var err error
+	t.once.Do(func() {
+		close(t.done)
+		if uerr := t.client.Unsubscribe(t.upTopic); uerr != nil {
+			log.Printf("取消订阅 %s 失败: %v", t.upTopic, uerr)
+		}
+		err = t.client.Disconnect()
+		t.frames.close()
+	})
+	return err
+}
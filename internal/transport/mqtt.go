@@ -0,0 +1,202 @@
+package transport
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+)
+
+// MQTT 传输参数
+type MQTTConfig struct {
+	UpTopic   string
+	DownTopic string
+	QoS       byte
+	// 非空时下行统一填写网关 EID，由网关按帧内 SensorID 转发
+	SinkEid string
+	// 断线期间下行缓存条数上限及落盘文件（为空时只缓存在内存）
+	OutboxSize int
+	OutboxFile string
+}
+
+// 通过 MQTT broker 与汇聚网关交互
+// 断线期间的下行进入有界缓存，重连并重新订阅后按顺序补发；
+// 下行信封 CorrelationID 为跟踪 ID，网关以同一 CorrelationID 上报即为确认
+type MQTT struct {
+	client *mqttclient.Managed
+	cfg    MQTTConfig
+	frames *frameQueue
+	outbox *outbox
+	sendMu sync.Mutex // 保证补发与新下行的先后顺序
+	// 以下由 sendMu 保护：连接正常但补发失败时的重试定时器
+	retry  *time.Timer
+	closed bool
+}
+
+// 连接正常但补发失败（如 broker 拒绝发布）时的重试间隔
+const outboxRetryInterval = 5 * time.Second
+
+// client 由 Start 负责启动连接
+func NewMQTT(client *mqttclient.Managed, cfg MQTTConfig) *MQTT {
+	t := &MQTT{
+		client: client,
+		cfg:    cfg,
+		frames: newFrameQueue(),
+		outbox: newOutbox(cfg.OutboxFile, cfg.OutboxSize),
+	}
+	client.OnStateChange(func(state string) {
+		if state == mqttclient.StateConnected {
+			go t.flushOutbox()
+		}
+	})
+	return t
+}
+
+func (t *MQTT) Name() string { return NameMQTT }
+
+func (t *MQTT) Start() error {
+	handler := mqttclient.PayloadHandler(func(payload []byte) {
+		f, ok := decodeEnvelopeFrame(NameMQTT, payload)
+		if ok && !t.frames.push(f) {
+			log.Printf("⚠ MQTT 上行通道已满，丢弃 len=%d", len(f.Data))
+		}
+	})
+	if err := t.client.Subscribe(t.cfg.UpTopic, t.cfg.QoS, handler); err != nil {
+		return err
+	}
+	t.client.Start()
+	return nil
+}
+
+// 解析上行信封：CorrelationID 匹配已跟踪下行时回填确认结果；
+// 返回信封中携带的帧，纯确认或错误消息返回 false
+func decodeEnvelopeFrame(name string, payload []byte) (Frame, bool) {
+	env, err := mqttclient.DecodeEnvelope(payload)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return Frame{}, false
+	}
+	acked := ackDownlink(env.CorrelationID, env.ErrorCode)
+	if env.ErrorCode != 0 {
+		rejectErrorEnvelope(name, env.CorrelationID, env.ErrorCode, env.Payload, acked)
+		return Frame{}, false
+	}
+	sp, raw, err := mqttclient.DecodeEnvelopePayload(env.Payload)
+	if err != nil {
+		if !acked {
+			log.Printf("❌ %v", err)
+		}
+		return Frame{}, false
+	}
+	return Frame{
+		Transport: name,
+		SinkEid:   sp.Eid,
+		Type:      sp.Type,
+		Timestamp: sp.Timestamp,
+		Received:  time.Now(),
+		Data:      raw,
+	}, true
+}
+
+// 未连接或已有待补发的下行时先入缓存并返回 ErrQueued，保证顺序；
+// 已连接时先尝试补发积压的下行
+func (t *MQTT) Send(id, eid string, frame []byte) error {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	reason := "MQTT 未连接"
+	if t.client.Connected() {
+		flushErr := t.flushLocked()
+		if t.outbox.len() == 0 {
+			err := t.publish(id, eid, frame)
+			if err != mqttclient.ErrNotConnected {
+				return err
+			}
+		} else {
+			reason = fmt.Sprintf("缓存下行补发失败（%v）", flushErr)
+		}
+	}
+	if t.outbox.push(id, eid, frame) {
+		log.Printf("⚠ 下行缓存已满(%d)，丢弃最早的一条", t.cfg.OutboxSize)
+	}
+	log.Printf("ℹ %s，下行已缓存，待发 %d 条", reason, t.outbox.len())
+	return ErrQueued
+}
+
+func (t *MQTT) publish(id, eid string, frame []byte) error {
+	if t.cfg.SinkEid != "" {
+		eid = t.cfg.SinkEid
+	}
+	body, err := mqttclient.EncodeSinkCommand(id, eid, strings.ToUpper(hex.EncodeToString(frame)))
+	if err != nil {
+		return err
+	}
+	return t.client.Publish(t.cfg.DownTopic, t.cfg.QoS, body)
+}
+
+// 重连或重试定时器到期后补发缓存的下行
+func (t *MQTT) flushOutbox() {
+	t.sendMu.Lock()
+	defer t.sendMu.Unlock()
+	t.retry = nil
+	if t.closed {
+		return
+	}
+	t.flushLocked()
+}
+
+// 按顺序补发，调用方持有 sendMu；连接正常但补发中断时定时重试，
+// 断线导致的中断等重连后再补发
+func (t *MQTT) flushLocked() error {
+	if t.outbox.len() == 0 {
+		return nil
+	}
+	n, err := t.outbox.drain(func(id, eid string, frame []byte) error {
+		if err := t.publish(id, eid, frame); err != nil {
+			return err
+		}
+		downlink.Update(id, downlink.StatusSent, nil)
+		return nil
+	})
+	if n > 0 || err != nil {
+		log.Printf("ℹ 已补发 %d 条缓存下行，剩余 %d 条", n, t.outbox.len())
+	}
+	if err != nil {
+		log.Printf("❌ %v", err)
+		if t.client.Connected() && !t.closed && t.retry == nil {
+			t.retry = time.AfterFunc(outboxRetryInterval, t.flushOutbox)
+		}
+	}
+	return err
+}
+
+// 连接状态回调：connecting / connected / disconnected
+func (t *MQTT) OnStateChange(fn func(state string)) {
+	t.client.OnStateChange(fn)
+}
+
+// 待补发的下行条数
+func (t *MQTT) Pending() int { return t.outbox.len() }
+
+func (t *MQTT) Frames() <-chan Frame { return t.frames.ch }
+
+func (t *MQTT) Close() error {
+	t.sendMu.Lock()
+	t.closed = true
+	if t.retry != nil {
+		t.retry.Stop()
+		t.retry = nil
+	}
+	t.sendMu.Unlock()
+	t.outbox.flush()
+	if err := t.client.Unsubscribe(t.cfg.UpTopic); err != nil {
+		log.Printf("取消订阅 %s 失败: %v", t.cfg.UpTopic, err)
+	}
+	t.client.Close()
+	t.frames.close()
+	return nil
+}
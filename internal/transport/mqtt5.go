@@ -0,0 +1,128 @@
+package transport
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+)
+
+// MQTT v5 传输参数
+type MQTT5Config struct {
+	UpTopic   string
+	DownTopic string
+	// 非空时下行统一填写网关 EID，由网关按帧内 SensorID 转发
+	SinkEid string
+	// 等待网关投递结果的时间
+	ResponseTimeout time.Duration
+}
+
+// 通过 MQTT v5 与汇聚网关交互：下行以请求/响应方式发送。
+// Send 发布后即返回，网关的投递结果异步回填下行跟踪；SendConfirmed 阻塞到网关返回结果，供命令下行使用。
+// 未连接时不缓存，直接返回错误，避免写命令误报成功
+type MQTT5 struct {
+	client *mqttclient.V5Client
+	cfg    MQTT5Config
+	frames *frameQueue
+}
+
+// client 由 Start 负责启动连接
+func NewMQTT5(client *mqttclient.V5Client, cfg MQTT5Config) *MQTT5 {
+	if cfg.ResponseTimeout <= 0 {
+		cfg.ResponseTimeout = 5 * time.Second
+	}
+	return &MQTT5{
+		client: client,
+		cfg:    cfg,
+		frames: newFrameQueue(),
+	}
+}
+
+func (t *MQTT5) Name() string { return NameMQTT5 }
+
+func (t *MQTT5) Start() error {
+	err := t.client.Subscribe(t.cfg.UpTopic, 1, func(payload []byte) {
+		f, ok := decodeEnvelopeFrame(NameMQTT5, payload)
+		if ok && !t.frames.push(f) {
+			log.Printf("⚠ MQTT v5 上行通道已满，丢弃 len=%d", len(f.Data))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return t.client.Start()
+}
+
+// 用户属性 eid 为目标传感器，frameType 为帧头中的报文类型，便于网关不解帧即可路由
+// 跟踪 ID 作为 CorrelationData，网关响应 ok 时标记为已接收，否则标记为失败
+func (t *MQTT5) Send(id, eid string, frame []byte) error {
+	body, props, err := t.request(id, eid, frame)
+	if err != nil {
+		return err
+	}
+	err = t.client.RequestAsync(id, t.cfg.DownTopic, body, props, t.cfg.ResponseTimeout, func(err error) {
+		if err != nil {
+			log.Printf("❌ MQTT v5 下发到 %s 未被网关接收: %v", eid, err)
+			downlink.Fail(id, err)
+			return
+		}
+		downlink.Update(id, downlink.StatusAccepted, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("下发到 %s: %w", eid, err)
+	}
+	return nil
+}
+
+// 同 Send，但阻塞到网关返回投递结果或超时
+func (t *MQTT5) SendConfirmed(id, eid string, frame []byte) error {
+	body, props, err := t.request(id, eid, frame)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.ResponseTimeout)
+	defer cancel()
+	if err := t.client.Request(ctx, id, t.cfg.DownTopic, body, props); err != nil {
+		return fmt.Errorf("下发到 %s: %w", eid, err)
+	}
+	downlink.Update(id, downlink.StatusAccepted, nil)
+	return nil
+}
+
+// 编码下行信封及用户属性
+func (t *MQTT5) request(id, eid string, frame []byte) ([]byte, map[string]string, error) {
+	props := map[string]string{"eid": strings.ToUpper(eid)}
+	if len(frame) > 6 {
+		props["frameType"] = fmt.Sprintf("%d", frame[6]&0x07)
+	}
+	target := eid
+	if t.cfg.SinkEid != "" {
+		target = t.cfg.SinkEid
+	}
+	body, err := mqttclient.EncodeSinkCommand(id, target, strings.ToUpper(hex.EncodeToString(frame)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, props, nil
+}
+
+// 连接状态回调：connecting / connected / disconnected
+func (t *MQTT5) OnStateChange(fn func(state string)) {
+	t.client.OnStateChange(fn)
+}
+
+// 下行不缓存，待补发条数恒为 0
+func (t *MQTT5) Pending() int { return 0 }
+
+func (t *MQTT5) Frames() <-chan Frame { return t.frames.ch }
+
+func (t *MQTT5) Close() error {
+	t.client.Close()
+	t.frames.close()
+	return nil
+}
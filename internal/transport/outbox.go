@@ -0,0 +1,155 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+)
+
+// 断线期间缓存的一条下行
+type outboxItem struct {
+	ID     string    `json:"id,omitempty"`
+	Eid    string    `json:"eid"`
+	Frame  []byte    `json:"frame"`
+	Queued time.Time `json:"queued"`
+}
+
+// 落盘合并间隔：期间的多次变化只写一次文件
+const outboxSaveDelay = 200 * time.Millisecond
+
+// 有界下行缓存：满时丢弃最旧的一条；path 非空时变化合并后落盘，重启后恢复
+type outbox struct {
+	mu        sync.Mutex
+	path      string
+	max       int
+	items     []outboxItem
+	saveTimer *time.Timer // 待写出的落盘，nil 表示文件已是最新
+}
+
+func newOutbox(path string, max int) *outbox {
+	if max <= 0 {
+		max = frameBufferSize
+	}
+	o := &outbox{path: path, max: max}
+	if err := o.load(); err != nil {
+		log.Printf("⚠ 读取下行缓存 %s 失败: %v", path, err)
+	}
+	return o
+}
+
+func (o *outbox) load() error {
+	if o.path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(o.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &o.items); err != nil {
+		return err
+	}
+	if n := len(o.items) - o.max; n > 0 {
+		o.items = o.items[n:]
+	}
+	if len(o.items) > 0 {
+		log.Printf("ℹ 恢复 %d 条未发送的下行", len(o.items))
+	}
+	return nil
+}
+
+// 写临时文件后改名，避免写一半时断电
+func (o *outbox) save() {
+	if o.path == "" {
+		return
+	}
+	b, err := json.Marshal(o.items)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(o.path), 0o755)
+	}
+	if err == nil {
+		tmp := o.path + ".tmp"
+		if err = os.WriteFile(tmp, b, 0o644); err == nil {
+			err = os.Rename(tmp, o.path)
+		}
+	}
+	if err != nil {
+		log.Printf("❌ 保存下行缓存 %s 失败: %v", o.path, err)
+	}
+}
+
+// 标记需要落盘，outboxSaveDelay 内的多次变化合并为一次写文件，调用方持有 mu
+func (o *outbox) scheduleSave() {
+	if o.path == "" || o.saveTimer != nil {
+		return
+	}
+	o.saveTimer = time.AfterFunc(outboxSaveDelay, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.saveTimer = nil
+		o.save()
+	})
+}
+
+// 立即写出尚未落盘的变化，关闭传输时调用
+func (o *outbox) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.saveTimer == nil {
+		return
+	}
+	o.saveTimer.Stop()
+	o.saveTimer = nil
+	o.save()
+}
+
+// 追加一条下行，返回是否因缓存已满丢弃了最旧的一条，被丢弃的下行标记为失败
+func (o *outbox) push(id, eid string, frame []byte) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	dropped := false
+	if len(o.items) >= o.max {
+		downlink.Fail(o.items[0].ID, errors.New("下行缓存已满，被丢弃"))
+		o.items = o.items[1:]
+		dropped = true
+	}
+	o.items = append(o.items, outboxItem{ID: id, Eid: eid, Frame: append([]byte(nil), frame...), Queued: time.Now()})
+	o.scheduleSave()
+	return dropped
+}
+
+// 按顺序逐条发送，遇到失败即停止，未发出的保留
+func (o *outbox) drain(send func(id, eid string, frame []byte) error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	sent := 0
+	var err error
+	for len(o.items) > 0 {
+		it := o.items[0]
+		if err = send(it.ID, it.Eid, it.Frame); err != nil {
+			err = fmt.Errorf("补发到 %s 失败: %w", it.Eid, err)
+			break
+		}
+		o.items = o.items[1:]
+		sent++
+	}
+	if sent > 0 {
+		o.scheduleSave()
+	}
+	return sent, err
+}
+
+func (o *outbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.items)
+}
@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"log"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/serialport"
+)
+
+// 通过串口 AT 指令直接驱动汇聚模块
+type Serial struct {
+	port   *serialport.Port
+	frames *frameQueue
+}
+
+// port 需已打开
+func NewSerial(port *serialport.Port) *Serial {
+	return &Serial{port: port, frames: newFrameQueue()}
+}
+
+func (t *Serial) Name() string { return NameSerial }
+
+func (t *Serial) Start() error {
+	t.port.Start(func(addr string, frame []byte) {
+		f := Frame{
+			Transport: NameSerial,
+			SinkEid:   addr,
+			Received:  time.Now(),
+			Data:      frame,
+		}
+		if !t.frames.push(f) {
+			log.Printf("⚠ 串口上行通道已满，丢弃 len=%d", len(frame))
+		}
+	})
+	return nil
+}
+
+// AT+DTX 直接寻址目标 EID，返回模块的 OK/ERROR 结果，OK 即视为网关已接收
+func (t *Serial) Send(id, eid string, frame []byte) error {
+	if err := t.port.Send(eid, frame); err != nil {
+		return err
+	}
+	downlink.Update(id, downlink.StatusAccepted, nil)
+	return nil
+}
+
+func (t *Serial) Frames() <-chan Frame { return t.frames.ch }
+
+func (t *Serial) Close() error {
+	err := t.port.Close()
+	t.frames.close()
+	return err
+}
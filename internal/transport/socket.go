@@ -0,0 +1,478 @@
+package transport
+
+// 汇聚网关经 TCP/UDP 直接转发帧，驱动监听端口
+// 消息内容为 SinkPayload（JSON）或 "<EID>,<HEX>"，分帧方式二选一：
+//   line:   每行一条
+//   length: 4 字节大端长度 + 消息内容
+// 下行经最近一次上报该 EID（网关或其下传感器）的连接/地址原路返回，
+// 按该网关上行所用的内容格式回复
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+)
+
+// 分帧方式
+const (
+	FramingLine   = "line"
+	FramingLength = "length"
+)
+
+const (
+	// 单条消息上限（length 分帧的长度、line 分帧的行长），防止异常对端耗尽内存
+	maxSocketMessage = 64 * 1024
+	// UDP 单个数据报上限
+	maxDatagram = 65535
+	// SensorID 长度，用于记录传感器经哪个网关上报
+	sensorIDLen = 6
+	// 单次下行写入超时，对端不读时不无限阻塞
+	socketWriteTimeout = 5 * time.Second
+	// UDP 网关地址超过该时间无上行即清除，连同经它的路由
+	udpPeerIdle = 10 * time.Minute
+)
+
+// 下行目标不存在可用连接
+var ErrNoRoute = errors.New("没有可用的网关连接")
+
+// 一个网关连接（TCP）或网关地址（UDP）
+// eid、text、seen 由 Socket.mu 保护
+type socketPeer struct {
+	eid  string // 网关 EID，首条上行后填写
+	text bool   // 最近一条上行为 "<EID>,<HEX>"，下行按同样格式回复
+	seen time.Time
+	conn net.Conn
+	pc   net.PacketConn
+	addr net.Addr
+	mu   sync.Mutex // 串行化写入
+}
+
+func (p *socketPeer) write(b []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		if err := p.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout)); err != nil {
+			return err
+		}
+		_, err := p.conn.Write(b)
+		return err
+	}
+	_, err := p.pc.WriteTo(b, p.addr)
+	return err
+}
+
+func (p *socketPeer) String() string {
+	if p.conn != nil {
+		return p.conn.RemoteAddr().String()
+	}
+	return p.addr.String()
+}
+
+// TCP/UDP 监听传输
+type Socket struct {
+	network string // tcp / udp
+	listen  string // 监听地址，如 ":9000"
+	framing string
+	frames  *frameQueue
+
+	ln net.Listener
+	pc net.PacketConn
+
+	mu       sync.Mutex
+	gateways map[string]*socketPeer // 网关 EID → 连接
+	sensors  map[string]*socketPeer // 传感器 EID → 转发它的网关连接
+	udpPeers map[string]*socketPeer // UDP 源地址 → 网关
+	pruned   time.Time              // 上次清理空闲 UDP 地址的时间
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// network 为 tcp 或 udp，framing 为 line 或 length（空为 line）
+func NewSocket(network, listen, framing string) (*Socket, error) {
+	network = strings.ToLower(network)
+	if network != NameTCP && network != NameUDP {
+		return nil, fmt.Errorf("不支持的网络类型 %q", network)
+	}
+	framing = strings.ToLower(framing)
+	if framing == "" {
+		framing = FramingLine
+	}
+	if framing != FramingLine && framing != FramingLength {
+		return nil, fmt.Errorf("不支持的分帧方式 %q", framing)
+	}
+	return &Socket{
+		network:  network,
+		listen:   listen,
+		framing:  framing,
+		frames:   newFrameQueue(),
+		gateways: make(map[string]*socketPeer),
+		sensors:  make(map[string]*socketPeer),
+		udpPeers: make(map[string]*socketPeer),
+		conns:    make(map[net.Conn]struct{}),
+	}, nil
+}
+
+func (t *Socket) Name() string { return t.network }
+
+// 实际监听地址，监听端口为 0 时可用于获取分配的端口
+func (t *Socket) Addr() net.Addr {
+	if t.ln != nil {
+		return t.ln.Addr()
+	}
+	if t.pc != nil {
+		return t.pc.LocalAddr()
+	}
+	return nil
+}
+
+func (t *Socket) Start() error {
+	if t.network == NameTCP {
+		ln, err := net.Listen("tcp", t.listen)
+		if err != nil {
+			return fmt.Errorf("监听 TCP %s 失败: %w", t.listen, err)
+		}
+		t.ln = ln
+		go t.acceptLoop()
+	} else {
+		pc, err := net.ListenPacket("udp", t.listen)
+		if err != nil {
+			return fmt.Errorf("监听 UDP %s 失败: %w", t.listen, err)
+		}
+		t.pc = pc
+		go t.udpLoop()
+	}
+	log.Printf("🔔 %s 监听 %s（%s 分帧）", strings.ToUpper(t.network), t.Addr(), t.framing)
+	return nil
+}
+
+func (t *Socket) acceptLoop() {
+	for {
+		conn, err := t.ln.Accept()
+		if err != nil {
+			if !t.isClosed() {
+				log.Printf("❌ TCP accept 失败: %v", err)
+			}
+			return
+		}
+		t.mu.Lock()
+		t.conns[conn] = struct{}{}
+		t.mu.Unlock()
+		go t.serveConn(conn)
+	}
+}
+
+// 逐条读取一个 TCP 连接上的上行消息
+func (t *Socket) serveConn(conn net.Conn) {
+	peer := &socketPeer{conn: conn}
+	log.Printf("ℹ 网关已连接: %s", peer)
+	defer func() {
+		conn.Close()
+		t.dropPeer(peer)
+		log.Printf("ℹ 网关已断开: %s", peer)
+	}()
+
+	r := bufio.NewReaderSize(conn, maxSocketMessage)
+	for {
+		msg, err := t.readMessage(r)
+		if err != nil {
+			if err != io.EOF && !t.isClosed() {
+				log.Printf("❌ 读取 %s 失败: %v", peer, err)
+			}
+			return
+		}
+		t.handleMessage(peer, msg)
+	}
+}
+
+// 每个数据报可包含一条或多条消息
+func (t *Socket) udpLoop() {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, addr, err := t.pc.ReadFrom(buf)
+		if err != nil {
+			if !t.isClosed() {
+				log.Printf("❌ UDP 读取失败: %v", err)
+			}
+			return
+		}
+		peer := t.udpPeer(addr)
+		r := bufio.NewReaderSize(bytes.NewReader(append([]byte(nil), buf[:n]...)), maxSocketMessage)
+		for {
+			msg, err := t.readMessage(r)
+			if err != nil {
+				if err != io.EOF {
+					log.Printf("❌ 解析 %s 数据报失败: %v", addr, err)
+				}
+				break
+			}
+			t.handleMessage(peer, msg)
+		}
+	}
+}
+
+func (t *Socket) udpPeer(addr net.Addr) *socketPeer {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.pruned) >= time.Minute {
+		t.pruneUDPLocked(now)
+	}
+	key := addr.String()
+	p, ok := t.udpPeers[key]
+	if !ok {
+		p = &socketPeer{pc: t.pc, addr: addr}
+		t.udpPeers[key] = p
+	}
+	p.seen = now
+	return p
+}
+
+// 清除超过 udpPeerIdle 无上行的 UDP 地址及经它的路由，调用方持有 mu
+func (t *Socket) pruneUDPLocked(now time.Time) {
+	t.pruned = now
+	for key, p := range t.udpPeers {
+		if now.Sub(p.seen) < udpPeerIdle {
+			continue
+		}
+		delete(t.udpPeers, key)
+		t.dropRoutesLocked(p)
+		log.Printf("ℹ 网关 %s 超过 %s 无上行，已清除", p, udpPeerIdle)
+	}
+}
+
+// 按分帧方式读取一条消息，空行跳过
+func (t *Socket) readMessage(r *bufio.Reader) ([]byte, error) {
+	if t.framing == FramingLength {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n == 0 || n > maxSocketMessage {
+			return nil, fmt.Errorf("消息长度非法: %d", n)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// 超长行丢弃到下一个换行，之后照常读取
+			n, err := discardLine(r)
+			log.Printf("⚠ 行长度超过 %d 字节，丢弃 %d 字节", maxSocketMessage, len(line)+n)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return append([]byte(nil), line...), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// 丢弃到下一个换行（含），返回丢弃的字节数
+func discardLine(r *bufio.Reader) (int, error) {
+	n := 0
+	for {
+		b, err := r.ReadSlice('\n')
+		n += len(b)
+		if err != bufio.ErrBufferFull {
+			return n, err
+		}
+	}
+}
+
+// 解码一条上行消息，记录路由并投递
+func (t *Socket) handleMessage(peer *socketPeer, msg []byte) {
+	sp, raw, err := decodeSocketMessage(msg)
+	if err != nil {
+		log.Printf("❌ %s 上行解析失败: %v", peer, err)
+		return
+	}
+	sp.Eid = strings.ToUpper(sp.Eid)
+	t.learnRoute(peer, sp.Eid, raw, msg[0] != '{')
+	f := Frame{
+		Transport: t.network,
+		SinkEid:   sp.Eid,
+		Type:      sp.Type,
+		Timestamp: sp.Timestamp,
+		Received:  time.Now(),
+		Data:      raw,
+	}
+	if !t.frames.push(f) {
+		log.Printf("⚠ %s 上行通道已满，丢弃 len=%d", strings.ToUpper(t.network), len(raw))
+	}
+}
+
+// JSON 形式按 SinkPayload 解析，否则按 "<EID>,<HEX>" 解析
+func decodeSocketMessage(msg []byte) (mqttclient.SinkPayload, []byte, error) {
+	if msg[0] == '{' {
+		return mqttclient.DecodeSinkPayload(msg)
+	}
+	eid, data, ok := strings.Cut(string(msg), ",")
+	if !ok {
+		return mqttclient.SinkPayload{}, nil, fmt.Errorf("无法识别的消息: %q", msg)
+	}
+	sp, err := mqttclient.NewSinkPayload(strings.TrimSpace(eid), data)
+	if err != nil {
+		return sp, nil, err
+	}
+	raw, _ := hex.DecodeString(sp.Data)
+	return sp, raw, nil
+}
+
+// 记录网关 EID 与其下传感器 EID 对应的连接，以及该网关使用的内容格式
+func (t *Socket) learnRoute(peer *socketPeer, gatewayEid string, raw []byte, text bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	peer.text = text
+	if gatewayEid != "" {
+		peer.eid = gatewayEid
+		t.gateways[gatewayEid] = peer
+	}
+	if len(raw) >= sensorIDLen {
+		t.sensors[strings.ToUpper(hex.EncodeToString(raw[:sensorIDLen]))] = peer
+	}
+}
+
+// TCP 连接断开后清理路由
+func (t *Socket) dropPeer(peer *socketPeer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, peer.conn)
+	t.dropRoutesLocked(peer)
+}
+
+// 删除经 peer 的网关、传感器路由，调用方持有 mu
+func (t *Socket) dropRoutesLocked(peer *socketPeer) {
+	for k, p := range t.gateways {
+		if p == peer {
+			delete(t.gateways, k)
+		}
+	}
+	for k, p := range t.sensors {
+		if p == peer {
+			delete(t.sensors, k)
+		}
+	}
+}
+
+// 查找下行连接：网关 EID → 传感器 EID → 唯一在线网关
+// 同时返回该连接的网关 EID（未知时为 eid 本身）及其是否使用 "<EID>,<HEX>" 格式
+func (t *Socket) route(eid string) (*socketPeer, string, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	eid = strings.ToUpper(eid)
+	p, ok := t.gateways[eid]
+	if !ok {
+		p, ok = t.sensors[eid]
+	}
+	if !ok && len(t.gateways) == 1 {
+		for _, g := range t.gateways {
+			p, ok = g, true
+		}
+	}
+	if !ok {
+		return nil, "", false, fmt.Errorf("%w: %s", ErrNoRoute, eid)
+	}
+	if p.eid != "" {
+		return p, p.eid, p.text, nil
+	}
+	return p, eid, p.text, nil
+}
+
+// 下行 Eid 填网关 EID，由网关按帧内 SensorID 转发
+func (t *Socket) Send(_, eid string, frame []byte) error {
+	peer, target, text, err := t.route(eid)
+	if err != nil {
+		return err
+	}
+	body, err := encodeSocketMessage(target, frame, text)
+	if err != nil {
+		return err
+	}
+	if err := peer.write(t.encode(body)); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", peer, err)
+	}
+	return nil
+}
+
+// text 为 true 时编码为 "<EID>,<HEX>"，否则为 SinkPayload JSON
+func encodeSocketMessage(eid string, frame []byte, text bool) ([]byte, error) {
+	if text {
+		return []byte(eid + "," + strings.ToUpper(hex.EncodeToString(frame))), nil
+	}
+	sp, err := mqttclient.NewSinkPayload(eid, hex.EncodeToString(frame))
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(sp)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 SinkPayload 失败: %w", err)
+	}
+	return body, nil
+}
+
+// 按分帧方式封装一条下行消息
+func (t *Socket) encode(body []byte) []byte {
+	if t.framing == FramingLength {
+		out := make([]byte, 4, 4+len(body))
+		binary.BigEndian.PutUint32(out, uint32(len(body)))
+		return append(out, body...)
+	}
+	return append(body, '\n')
+}
+
+func (t *Socket) Frames() <-chan Frame { return t.frames.ch }
+
+func (t *Socket) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	conns := make([]net.Conn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+
+	var err error
+	if t.ln != nil {
+		err = t.ln.Close()
+	}
+	if t.pc != nil {
+		err = t.pc.Close()
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	t.frames.close()
+	return err
+}
+
+func (t *Socket) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
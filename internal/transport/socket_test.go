@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+)
+
+// 启动监听并建立一条 TCP 连接
+func dialSocket(t *testing.T, framing string) (*Socket, net.Conn) {
+	t.Helper()
+	s, err := NewSocket(NameTCP, "127.0.0.1:0", framing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, conn
+}
+
+func waitFrame(t *testing.T, s *Socket) Frame {
+	t.Helper()
+	select {
+	case f := <-s.Frames():
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到上行帧")
+	}
+	return Frame{}
+}
+
+// 超长行被丢弃，连接保持，后续消息照常解析
+func TestSocketLineOverflow(t *testing.T) {
+	s, err := NewSocket(NameTCP, "127.0.0.1:0", FramingLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		conn.Write(bytes.Repeat([]byte{'A'}, 3*maxSocketMessage))
+		conn.Write([]byte("\n238A0841D828,238A0841D8280102\n"))
+	}()
+
+	select {
+	case f := <-s.Frames():
+		if f.SinkEid != "238A0841D828" || len(f.Data) != 8 {
+			t.Fatalf("上行帧 = %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("超长行之后未收到上行帧")
+	}
+}
+
+// 长度前缀分帧：上行 SinkPayload JSON，下行经同一连接按同样格式返回
+func TestSocketLengthFramingRoundTrip(t *testing.T) {
+	s, conn := dialSocket(t, FramingLength)
+
+	sp, err := mqttclient.NewSinkPayload("238A0841D828", "238A0841D8280102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(sp)
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	if _, err := conn.Write(append(msg, body...)); err != nil {
+		t.Fatal(err)
+	}
+	if f := waitFrame(t, s); f.SinkEid != "238A0841D828" || len(f.Data) != 8 {
+		t.Fatalf("上行帧 = %+v", f)
+	}
+
+	// 按传感器 EID 下行，经上报它的网关连接返回
+	if err := s.Send("", "238A0841D828", []byte{0xAA, 0x55}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var n uint32
+	if err := binary.Read(conn, binary.BigEndian, &n); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, n)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	got, raw, err := mqttclient.DecodeSinkPayload(reply)
+	if err != nil {
+		t.Fatalf("下行不是 SinkPayload: %q, %v", reply, err)
+	}
+	if got.Eid != "238A0841D828" || !bytes.Equal(raw, []byte{0xAA, 0x55}) {
+		t.Fatalf("下行 = %+v", got)
+	}
+}
+
+// "<EID>,<HEX>" 上行的网关收到同样格式的下行
+func TestSocketTextPeerReply(t *testing.T) {
+	s, conn := dialSocket(t, FramingLine)
+
+	if _, err := conn.Write([]byte("238A0841D828,238A0841D8280102\n")); err != nil {
+		t.Fatal(err)
+	}
+	waitFrame(t, s)
+
+	if err := s.Send("", "238A0841D828", []byte{0xAA, 0x55}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "238A0841D828,AA55\n" {
+		t.Fatalf("下行 = %q", line)
+	}
+}
+
+// 长期无上行的 UDP 网关地址及其路由被清除
+func TestSocketPruneUDP(t *testing.T) {
+	s, err := NewSocket(NameUDP, "127.0.0.1:0", FramingLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}
+	p := s.udpPeer(addr)
+	s.learnRoute(p, "238A0841D828", nil, true)
+	if _, _, _, err := s.route("238A0841D828"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	p.seen = time.Now().Add(-2 * udpPeerIdle)
+	s.pruneUDPLocked(time.Now())
+	s.mu.Unlock()
+	if _, _, _, err := s.route("238A0841D828"); err == nil {
+		t.Fatal("清除后仍能路由")
+	}
+}
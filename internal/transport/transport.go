@@ -0,0 +1,121 @@
+package transport
+
+// 汇聚网关传输抽象：驱动、解析器只依赖 Transport 接口，
+// 具体走 MQTT、串口还是内存回环由配置决定
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device-wiresink-go/internal/downlink"
+	"github.com/linjuya-lu/device-wiresink-go/internal/mqttclient"
+)
+
+// 传输方式
+const (
+	NameMQTT       = "mqtt"
+	NameMQTT5      = "mqtt5"
+	NameMessageBus = "messagebus"
+	NameSerial     = "serial"
+	NameLoopback   = "loopback"
+	NameTCP        = "tcp"
+	NameUDP        = "udp"
+)
+
+// 上行通道缓冲大小
+const frameBufferSize = 128
+
+// 一条上行原始帧及其元数据
+type Frame struct {
+	Transport string    // 来源传输方式
+	SinkEid   string    // 转发该帧的汇聚模块 EID，串口模式为 +DRX 中的地址
+	Type      string    // SinkPayload.Type
+	Timestamp uint64    // 网关填写的世纪秒，未知为 0
+	Received  time.Time // 驱动收到的时间
+	Data      []byte    // 原始帧：SensorID + 头 + 内容 + CRC
+}
+
+// 汇聚网关传输
+type Transport interface {
+	// 传输方式名称
+	Name() string
+	// 建立连接/订阅并开始接收上行
+	Start() error
+	// 经汇聚网关把 frame 下发给 eid；id 为下行跟踪 ID（协议应答不跟踪，为空），带信封的传输写入 CorrelationID
+	Send(id, eid string, frame []byte) error
+	// 上行帧通道，Close 后关闭
+	Frames() <-chan Frame
+	// 断开连接并释放资源
+	Close() error
+}
+
+// 下行暂存在断线缓存中，连接恢复后补发，调用方视为已受理
+var ErrQueued = errors.New("下行已缓存，待连接恢复后补发")
+
+// 网关确认：信封 CorrelationID 对应已跟踪的下行时回填状态，返回是否匹配
+func ackDownlink(correlationID string, errorCode int) bool {
+	if correlationID == "" {
+		return false
+	}
+	return downlink.Ack(correlationID, errorCode)
+}
+
+// ErrorCode 非 0 的信封只携带错误描述，不作为帧处理：
+// 匹配到已跟踪下行时该下行已被标记为失败，否则无从归属，记录错误后拒收
+func rejectErrorEnvelope(name, correlationID string, errorCode int, payload interface{}, acked bool) {
+	text := mqttclient.EnvelopeErrorText(payload)
+	if acked {
+		log.Printf("⚠ [%s] 网关报告下行 %s 投递失败 errorCode=%d: %s", name, correlationID, errorCode, text)
+		return
+	}
+	log.Printf("❌ [%s] 拒收错误信封：errorCode=%d correlationID=%q 未匹配任何下行: %s", name, errorCode, correlationID, text)
+}
+
+// 可同步等待网关投递结果的传输（如 mqtt5）：命令下行经 SendConfirmed 发送，
+// 网关的投递结果直接作为命令的成功或失败；其余下行仍走 Send，不阻塞
+type ConfirmSender interface {
+	SendConfirmed(id, eid string, frame []byte) error
+}
+
+// 可报告连接状态的传输（如 mqtt），状态取值见 mqttclient.StateXxx
+type StateNotifier interface {
+	OnStateChange(fn func(state string))
+	// 断线期间缓存、待补发的下行条数
+	Pending() int
+}
+
+// 上行帧队列：关闭后的投递直接丢弃，避免向已关闭通道写入
+type frameQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	ch     chan Frame
+}
+
+func newFrameQueue() *frameQueue {
+	return &frameQueue{ch: make(chan Frame, frameBufferSize)}
+}
+
+// 非阻塞投递上行帧，通道满或已关闭时丢弃并返回 false
+func (q *frameQueue) push(f Frame) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.ch <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *frameQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+}